@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/daphen/notes-cli/internal/config"
+)
+
+// configCmd handles `notes-cli config show [--resolved]` and `config
+// reference`. Plain show prints the file as written; --resolved prints the
+// settings the selected vault ends up with after env and flag overrides,
+// and where each came from.
+func configCmd(cfg *config.Config, vaultName string, args []string) error {
+	if len(args) == 1 && args[0] == "reference" {
+		fmt.Print(config.Reference())
+		return nil
+	}
+	if len(args) == 0 || args[0] != "show" {
+		return fmt.Errorf("usage: notes-cli config show [--resolved] | config reference")
+	}
+
+	fs := flag.NewFlagSet("config show", flag.ExitOnError)
+	resolved := fs.Bool("resolved", false, "Show effective settings and their origin")
+	fs.Parse(args[1:])
+
+	if !*resolved {
+		if cfg.Path == "" {
+			return fmt.Errorf("no config file - run 'notes-cli -init' to create one")
+		}
+		data, err := os.ReadFile(cfg.Path)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("# %s\n%s", cfg.Path, data)
+		return nil
+	}
+
+	vc, err := cfg.Layer(vaultName, flag.CommandLine)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Vault: %s\n", vc.Name)
+	if cfg.Path != "" {
+		fmt.Printf("File:  %s\n\n", cfg.Path)
+	} else {
+		fmt.Printf("File:  (none)\n\n")
+	}
+
+	// 🔵 GO CONCEPT: text/tabwriter
+	// Cells separated by \t are padded into aligned columns on Flush.
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tFROM")
+	for _, s := range vc.Settings() {
+		value := s.Value
+		if s.Secret && value != "" {
+			value = "********"
+		}
+		if value == "" {
+			value = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Key, value, s.Origin)
+	}
+	tw.Flush()
+
+	if err := vc.Validate(); err != nil {
+		fmt.Printf("\nProblems:\n%v\n", err)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/daphen/notes-cli/internal/client"
+	"github.com/daphen/notes-cli/internal/daemon"
+	"github.com/daphen/notes-cli/internal/journal"
+	"github.com/daphen/notes-cli/internal/ui"
+)
+
+// conflictResolver lets the TUI list and settle conflicts recorded by
+// applyRemoteNote
+type conflictResolver struct {
+	v *vault
+
+	// Set when a daemon syncs the vault. Its sync state is the one that
+	// counts, so it is reloaded before use and the daemon paused while
+	// this process changes it.
+	ctl        *daemon.Client
+	daemonName string
+}
+
+// resolveLease bounds how long the daemon stays paused for one
+// resolution, should this process die before resuming it
+const resolveLease = 2 * time.Minute
+
+// exclusive runs fn with the daemon (if any) paused and the sync state
+// fresh from disk
+func (r *conflictResolver) exclusive(fn func() error) error {
+	if r.ctl == nil {
+		return fn()
+	}
+
+	params := daemon.VaultParams{Vault: r.daemonName, Lease: int(resolveLease / time.Second)}
+	if err := r.ctl.Call(daemon.MethodPause, params, nil); err != nil {
+		return fmt.Errorf("failed to pause daemon: %w", err)
+	}
+	// 🔵 GO CONCEPT: Deferred closures
+	// Resume even if fn fails, so the daemon never stays paused.
+	defer r.ctl.Call(daemon.MethodResume, params, nil)
+
+	if err := r.v.st.Reload(); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// Conflicts pairs each recorded remote version with the file on disk
+func (r *conflictResolver) Conflicts() ([]ui.Conflict, error) {
+	if r.ctl != nil {
+		if err := r.v.st.Reload(); err != nil {
+			return nil, err
+		}
+	}
+
+	var conflicts []ui.Conflict
+	for _, path := range r.v.st.ConflictPaths() {
+		c, ok := r.v.st.GetConflict(path)
+		if !ok {
+			continue
+		}
+
+		local, err := os.ReadFile(filepath.Join(r.v.cfg.NotesDir, path))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		conflicts = append(conflicts, ui.Conflict{
+			Path:   path,
+			Local:  string(local),
+			Remote: c.RemoteContent,
+		})
+	}
+	return conflicts, nil
+}
+
+// Resolve pushes content as the new version of path, then writes it to disk.
+// Pushing first means a failed push leaves the conflict in place.
+func (r *conflictResolver) Resolve(path, content string) error {
+	return r.exclusive(func() error {
+		return r.resolve(path, content)
+	})
+}
+
+func (r *conflictResolver) resolve(path, content string) error {
+	content, err := r.push(path, content)
+	if err != nil {
+		return err
+	}
+
+	fullPath := filepath.Join(r.v.cfg.NotesDir, path)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	r.v.st.ResolveConflict(path)
+	return r.v.st.Save()
+}
+
+// KeepBoth saves the server version next to the note as a new note and
+// keeps the local version at the original path
+func (r *conflictResolver) KeepBoth(path string) (copyPath string, err error) {
+	err = r.exclusive(func() error {
+		copyPath, err = r.keepBoth(path)
+		return err
+	})
+	return copyPath, err
+}
+
+func (r *conflictResolver) keepBoth(path string) (string, error) {
+	c, ok := r.v.st.GetConflict(path)
+	if !ok {
+		return "", fmt.Errorf("no conflict recorded for %s", path)
+	}
+
+	local, err := os.ReadFile(filepath.Join(r.v.cfg.NotesDir, path))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	copyPath := r.copyPath(path)
+	copyFull := filepath.Join(r.v.cfg.NotesDir, copyPath)
+	if err := os.WriteFile(copyFull, []byte(c.RemoteContent), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", copyPath, err)
+	}
+	if _, err := r.push(copyPath, c.RemoteContent); err != nil {
+		return "", err
+	}
+
+	return copyPath, r.resolve(path, string(local))
+}
+
+// copyPath picks an unused "name (server copy).md" path next to path
+func (r *conflictResolver) copyPath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+
+	candidate := base + " (server copy)" + ext
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(r.v.cfg.NotesDir, candidate)); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s (server copy %d)%s", base, i, ext)
+	}
+}
+
+// push sends one note to the server and records it as synced. Returns
+// the content sent, which has secrets redacted if configured.
+func (r *conflictResolver) push(path, content string) (pushed string, err error) {
+	defer func() {
+		r.v.jr.Log(journal.Push, "resolve", path, journalResult(err), err)
+		logResult(r.v, "push", "resolve", path, err)
+	}()
+
+	n, err := buildNote(path, content, "update")
+	if err != nil {
+		return "", err
+	}
+	if n, err = checkSecrets(r.v, n); err != nil {
+		return "", err
+	}
+
+	resp, err := r.v.apiClient.Push(context.Background(), []client.Note{n})
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(resp.Accepted, n.Path) {
+		return "", fmt.Errorf("server did not accept %s", n.Path)
+	}
+
+	r.v.st.Record(n.Path, n.Checksum, "")
+	return n.Content, nil
+}
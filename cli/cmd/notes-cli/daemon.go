@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/daphen/notes-cli/internal/config"
+	"github.com/daphen/notes-cli/internal/daemon"
+	"github.com/daphen/notes-cli/internal/ui"
+)
+
+// daemonCmd handles `notes-cli daemon [run|status|pause|resume|sync|events]`.
+// run starts the daemon; the rest talk to it over the control socket.
+func daemonCmd(cfg *config.Config, metricsAddr, vaultName string, args []string) error {
+	sub := "run"
+	if len(args) > 0 {
+		sub, args = args[0], args[1:]
+	}
+
+	if sub == "run" {
+		return runDaemon(cfg, metricsAddr, vaultName)
+	}
+
+	fs := flag.NewFlagSet("daemon "+sub, flag.ExitOnError)
+	jsonOut := fs.Bool("json", false, "Print JSON")
+	fs.Parse(args)
+
+	path, err := daemon.SocketPath()
+	if err != nil {
+		return err
+	}
+	ctl, err := daemon.Dial(path)
+	if err != nil {
+		return err
+	}
+	defer ctl.Close()
+
+	params := daemon.VaultParams{Vault: vaultName}
+
+	switch sub {
+	case "status":
+		st, err := ctl.Status()
+		if err != nil {
+			return err
+		}
+		if *jsonOut {
+			return json.NewEncoder(os.Stdout).Encode(st)
+		}
+		printDaemonStatus(st)
+		return nil
+
+	case "pause":
+		return ctl.Call(daemon.MethodPause, params, nil)
+
+	case "resume":
+		return ctl.Call(daemon.MethodResume, params, nil)
+
+	case "sync":
+		return ctl.Call(daemon.MethodSyncNow, params, nil)
+
+	case "events":
+		enc := json.NewEncoder(os.Stdout)
+		return ctl.Tail(func(e daemon.Event) {
+			if vaultName != "" && e.Vault != vaultName {
+				return
+			}
+			if *jsonOut {
+				enc.Encode(e)
+				return
+			}
+			line := fmt.Sprintf("%s  %-8s  %-8s  %s", e.Time.Local().Format("15:04:05"), e.Vault, e.Kind, e.Path)
+			if e.Message != "" {
+				line += "  " + e.Message
+			}
+			fmt.Println(line)
+		})
+	}
+
+	return fmt.Errorf("unknown daemon command %q (run, status, pause, resume, sync, events)", sub)
+}
+
+func printDaemonStatus(st daemon.Status) {
+	fmt.Printf("notes-cli daemon %s, pid %d, up %s\n\n", st.Version, st.PID, time.Since(st.Started).Round(time.Second))
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VAULT\tSTATE\tPUSHED\tPULLED\tCONFLICTS\tLAST SYNC")
+	for _, v := range st.Vaults {
+		state := "syncing"
+		if v.Paused {
+			state = fmt.Sprintf("paused (%d pending)", v.Pending)
+		}
+		last := "-"
+		if !v.LastSync.IsZero() {
+			last = v.LastSync.Local().Format("15:04:05")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\n", v.Name, state, v.Pushed, v.Pulled, v.Conflicts, last)
+	}
+	tw.Flush()
+
+	for _, v := range st.Vaults {
+		if v.LastError != "" {
+			fmt.Printf("\n%s: last error: %s\n", v.Name, v.LastError)
+		}
+	}
+}
+
+// runDaemon syncs the selected vault, or all of them, and serves the
+// control socket and metrics until interrupted
+func runDaemon(cfg *config.Config, metricsAddr, vaultName string) error {
+	path, err := daemon.SocketPath()
+	if err != nil {
+		return err
+	}
+
+	h := &daemonHandler{started: time.Now()}
+	srv, err := daemon.Listen(path, h)
+	if err != nil {
+		return err
+	}
+	defer srv.Close()
+
+	if vaultName == "" {
+		vaultName = os.Getenv(config.VaultEnv)
+	}
+	names := cfg.VaultNames()
+	if vaultName != "" {
+		names = []string{vaultName}
+	} else if len(names) == 0 {
+		// Everything comes from the environment
+		names = []string{config.DefaultVaultName}
+	}
+	if len(names) > 1 {
+		if err := config.CheckOverrides(flag.CommandLine); err != nil {
+			return err
+		}
+		if err := cfg.CheckVaultDirs(flag.CommandLine); err != nil {
+			return err
+		}
+	}
+
+	// SIGTERM lets in-flight pushes finish and removes the socket
+	ctx, release := signalContext()
+	defer release()
+
+	for _, name := range names {
+		v, err := connectVault(cfg, name)
+		if err != nil {
+			fmt.Printf("[%s] Skipping vault: %v\n", name, err)
+			logger.Error("skipping vault", "vault", name, "err", err)
+			continue
+		}
+		if len(names) > 1 {
+			v.tag = name
+		}
+
+		show := printEvent(v)
+		loop, err := newSyncLoop(ctx, v, func(e daemon.Event) {
+			show(e)
+			srv.Publish(e)
+		})
+		if err != nil {
+			fmt.Printf("[%s] Skipping vault: %v\n", name, err)
+			logger.Error("skipping vault", "vault", name, "err", err)
+			continue
+		}
+		h.loops = append(h.loops, loop)
+	}
+	if len(h.loops) == 0 {
+		return fmt.Errorf("no vault could be started")
+	}
+
+	stopMetrics, err := serveMetrics(metricsAddr)
+	if err != nil {
+		return err
+	}
+	defer stopMetrics()
+
+	go srv.Serve()
+	fmt.Printf("Daemon listening on %s\n", srv.Path())
+
+	var wg sync.WaitGroup
+	for _, loop := range h.loops {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			loop.v.printf("Watching %s for changes%s...\n", loop.v.cfg.NotesDir, watching(loop.w))
+			loop.run()
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// daemonHandler answers control requests for the daemon's sync loops
+type daemonHandler struct {
+	started time.Time
+	loops   []*syncLoop
+}
+
+func (h *daemonHandler) Status() daemon.Status {
+	st := daemon.Status{
+		PID:     os.Getpid(),
+		Started: h.started,
+		Version: version,
+	}
+	for _, l := range h.loops {
+		st.Vaults = append(st.Vaults, l.snapshot())
+	}
+	return st
+}
+
+func (h *daemonHandler) Pause(vault string, lease time.Duration) error {
+	loops, err := h.pick(vault)
+	for _, l := range loops {
+		l.pause(lease)
+	}
+	return err
+}
+
+func (h *daemonHandler) Resume(vault string) error {
+	loops, err := h.pick(vault)
+	if err != nil {
+		return err
+	}
+	for _, l := range loops {
+		if err := l.resume(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *daemonHandler) SyncNow(vault string) error {
+	loops, err := h.pick(vault)
+	if err != nil {
+		return err
+	}
+	for _, l := range loops {
+		if err := l.syncNow(); err != nil {
+			return fmt.Errorf("%s: %w", l.v.cfg.Name, err)
+		}
+	}
+	return nil
+}
+
+// pick returns the loop for a vault, or all loops for ""
+func (h *daemonHandler) pick(vault string) ([]*syncLoop, error) {
+	if vault == "" {
+		return h.loops, nil
+	}
+	for _, l := range h.loops {
+		if l.v.cfg.Name == vault {
+			return []*syncLoop{l}, nil
+		}
+	}
+	return nil, fmt.Errorf("daemon is not syncing vault %q", vault)
+}
+
+// daemonFor connects to a running daemon that syncs v's notes directory.
+// It returns nil if there is none, along with the daemon's name for the
+// vault.
+func daemonFor(v *vault) (*daemon.Client, string) {
+	path, err := daemon.SocketPath()
+	if err != nil {
+		return nil, ""
+	}
+	ctl, err := daemon.Dial(path)
+	if err != nil {
+		return nil, ""
+	}
+
+	st, err := ctl.Status()
+	if err == nil {
+		for _, vs := range st.Vaults {
+			if sameDir(vs.NotesDir, v.cfg.NotesDir) {
+				return ctl, vs.Name
+			}
+		}
+	}
+	ctl.Close()
+	return nil, ""
+}
+
+func sameDir(a, b string) bool {
+	a, errA := filepath.Abs(a)
+	b, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && a == b
+}
+
+// followDaemon shows a running daemon's activity for one vault in the TUI,
+// in place of backgroundSync, until ctx is done
+func followDaemon(ctx context.Context, name string, p *tea.Program) {
+	path, err := daemon.SocketPath()
+	if err != nil {
+		p.Send(ui.SendSyncError(err))
+		return
+	}
+	events, err := daemon.Dial(path)
+	if err != nil {
+		p.Send(ui.SendSyncError(err))
+		return
+	}
+	stop := context.AfterFunc(ctx, func() { events.Close() })
+	defer stop()
+
+	p.Send(ui.SendSyncStatus("Synced by the notes-cli daemon"))
+
+	events.Tail(func(e daemon.Event) {
+		if e.Vault != name {
+			return
+		}
+		switch e.Kind {
+		case daemon.EventPush:
+			p.Send(ui.SendSyncSuccess(e.Path))
+		case daemon.EventPull:
+			p.Send(ui.SendSyncSuccess(e.Path))
+			p.Send(ui.SendNotesChanged())
+		case daemon.EventConflict:
+			p.Send(ui.SendConflictsChanged())
+		case daemon.EventError, daemon.EventSkip:
+			p.Send(ui.SendSyncError(fmt.Errorf("%s: %s", e.Path, e.Message)))
+		case daemon.EventPaused:
+			p.Send(ui.SendSyncStatus("Daemon paused"))
+		case daemon.EventResumed:
+			p.Send(ui.SendSyncStatus("Synced by the notes-cli daemon"))
+		}
+	})
+}
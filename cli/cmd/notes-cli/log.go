@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/daphen/notes-cli/internal/journal"
+)
+
+// logCmd prints the sync journal, oldest first. Remaining arguments filter
+// entries by words, e.g. `notes-cli log pull error`.
+func logCmd(jr *journal.Journal, args []string) error {
+	fs := flag.NewFlagSet("log", flag.ExitOnError)
+	limit := fs.Int("n", 50, "Show only the last N entries (0 = all)")
+	jsonOut := fs.Bool("json", false, "Print entries as JSON Lines")
+	fs.Parse(args)
+
+	entries, err := jr.Read()
+	if err != nil {
+		return err
+	}
+
+	query := strings.Join(fs.Args(), " ")
+	var matched []journal.Entry
+	for _, e := range entries {
+		if e.Matches(query) {
+			matched = append(matched, e)
+		}
+	}
+	if *limit > 0 && len(matched) > *limit {
+		matched = matched[len(matched)-*limit:]
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		for _, e := range matched {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(matched) == 0 {
+		fmt.Println("No sync activity recorded.")
+		return nil
+	}
+
+	for _, e := range matched {
+		line := fmt.Sprintf("%s  %-4s  %-8s  %s",
+			e.Time.Local().Format("2006-01-02 15:04:05"), e.Direction, e.Result, e.Path)
+		if e.Action != "" && e.Action != "update" {
+			line += " (" + e.Action + ")"
+		}
+		if e.Error != "" {
+			line += ": " + e.Error
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/daphen/notes-cli/internal/config"
+	"github.com/daphen/notes-cli/internal/logging"
+)
+
+// logger is the diagnostic log, set up from the log_* settings by
+// setupLogging. Vaults log through it with their name attached.
+var (
+	logger  = logging.Discard
+	logFile io.Closer
+)
+
+// setupLogging opens the log configured by the top-level settings,
+// environment and flags, and makes it slog's default
+func setupLogging(gc *config.Config) error {
+	l, f, err := logging.New(logging.Options{
+		Level:      gc.LogLevel,
+		Format:     gc.LogFormat,
+		File:       gc.LogFile,
+		MaxSize:    gc.LogMaxSize,
+		MaxBackups: gc.LogMaxBackups,
+	})
+	if err != nil {
+		return err
+	}
+	logger, logFile = l, f
+	slog.SetDefault(l)
+	return nil
+}
+
+// closeLog flushes and closes the log file, if any
+func closeLog() {
+	if logFile != nil {
+		logFile.Close()
+	}
+}
+
+// fatalf reports an error that ends the program on stderr and in the log,
+// then exits with status 1
+func fatalf(format string, args ...any) {
+	exitf(1, format, args...)
+}
+
+// exitf is fatalf with another exit status
+func exitf(code int, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	logger.Error(msg)
+	closeLog()
+	fmt.Fprintln(os.Stderr, msg)
+	os.Exit(code)
+}
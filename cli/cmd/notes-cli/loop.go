@@ -0,0 +1,481 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/daphen/notes-cli/internal/client"
+	"github.com/daphen/notes-cli/internal/daemon"
+	"github.com/daphen/notes-cli/internal/hooks"
+	"github.com/daphen/notes-cli/internal/journal"
+	"github.com/daphen/notes-cli/internal/metrics"
+	"github.com/daphen/notes-cli/internal/note"
+	"github.com/daphen/notes-cli/internal/secrets"
+	"github.com/daphen/notes-cli/internal/watcher"
+)
+
+// syncLoop keeps one vault in sync without a TUI: it pushes local changes
+// from the watcher and applies the server's change feed. -watch and the
+// daemon run one per vault; the daemon can pause and resume it.
+type syncLoop struct {
+	v    *vault
+	w    *watcher.Watcher
+	emit func(daemon.Event)
+
+	// ctx ends the loop. Pushes and pulls run under work, which outlives
+	// it by shutdownGrace so a sync in flight can finish.
+	ctx        context.Context
+	work       context.Context
+	cancelWork context.CancelFunc
+
+	// Held while pushing or pulling, so pause can wait for work in flight
+	syncMu sync.Mutex
+	// Serialises pause and resume, which may race a lease running out
+	ctlMu sync.Mutex
+
+	mu         sync.Mutex
+	paused     bool
+	lease      *time.Timer                   // Resumes a leased pause, nil if paused until resume
+	leaseGen   int                           // Tells a timer that fired from the current lease
+	pending    map[string]watcher.FileChange // Changes seen while paused
+	queue      <-chan watcher.FileChange     // The watcher's, buffering changes not yet read
+	inFlight   int                           // Changes of the batch being pushed not yet sent
+	feedCancel context.CancelFunc
+	feedDone   chan struct{}
+	status     daemon.VaultStatus
+}
+
+// newSyncLoop prepares a loop that runs until ctx is done
+func newSyncLoop(ctx context.Context, v *vault, emit func(daemon.Event)) (*syncLoop, error) {
+	w, err := v.newWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	work, cancelWork := withGrace(ctx, shutdownGrace)
+	return &syncLoop{
+		v:          v,
+		w:          w,
+		emit:       emit,
+		ctx:        ctx,
+		work:       work,
+		cancelWork: cancelWork,
+		pending:    make(map[string]watcher.FileChange),
+		status: daemon.VaultStatus{
+			Name:     v.cfg.Name,
+			NotesDir: v.cfg.NotesDir,
+		},
+	}, nil
+}
+
+// run pushes changes until the loop's context is done. Changes queued by
+// then are still pushed within the grace period; whatever is left over is
+// picked up by the next full sync.
+func (l *syncLoop) run() {
+	defer l.w.Close()
+	defer l.cancelWork()
+
+	l.startFeed()
+	defer l.stopFeed()
+
+	changes := l.w.Watch(l.ctx)
+	l.mu.Lock()
+	l.queue = changes
+	l.mu.Unlock()
+	for change := range changes {
+		if l.work.Err() != nil {
+			break
+		}
+		batch := append([]watcher.FileChange{change}, watcher.Queued(changes)...)
+
+		l.mu.Lock()
+		if l.paused {
+			// Only the latest version of each file matters
+			for _, change := range batch {
+				l.pending[change.Path] = change
+			}
+			l.mu.Unlock()
+			l.reportQueue()
+			continue
+		}
+		l.mu.Unlock()
+
+		l.pushBatch(batch)
+		if l.ctx.Err() == nil {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	if err := l.v.st.Save(); err != nil {
+		l.record(daemon.Event{Kind: daemon.EventError, Message: "failed to save sync state: " + err.Error()})
+	}
+}
+
+// pushBatch sends notes one at a time and attachments together
+func (l *syncLoop) pushBatch(changes []watcher.FileChange) {
+	left := len(changes)
+	l.setInFlight(left)
+	defer l.setInFlight(0)
+
+	var atts []watcher.FileChange
+	for _, change := range changes {
+		if change.Attachment {
+			atts = append(atts, change)
+			continue
+		}
+		l.push(change)
+		left--
+		l.setInFlight(left)
+	}
+	if len(atts) > 0 {
+		l.pushAttachments(atts)
+	}
+}
+
+func (l *syncLoop) setInFlight(n int) {
+	l.mu.Lock()
+	l.inFlight = n
+	l.mu.Unlock()
+	l.reportQueue()
+}
+
+// reportQueue sets notes_queue_depth to the changes not pushed yet: those
+// buffered by the watcher, held back while paused and left in the batch
+// being pushed
+func (l *syncLoop) reportQueue() {
+	l.mu.Lock()
+	depth := len(l.queue) + len(l.pending) + l.inFlight
+	l.mu.Unlock()
+	metrics.QueueDepth.Set(float64(depth), l.v.cfg.Name)
+}
+
+// pushAttachments sends attachment changes in one batch and reports the
+// outcome
+func (l *syncLoop) pushAttachments(changes []watcher.FileChange) {
+	l.syncMu.Lock()
+	defer l.syncMu.Unlock()
+	if l.holdBack(changes...) {
+		return
+	}
+
+	sent, err := pushAttachmentChanges(l.work, l.v, changes)
+	for _, path := range sent {
+		l.record(daemon.Event{Kind: daemon.EventPush, Path: path})
+	}
+	switch {
+	case errors.Is(err, client.ErrNoAttachments):
+		l.record(daemon.Event{Kind: daemon.EventSkip, Path: changes[0].Path, Message: err.Error()})
+	case err != nil:
+		l.record(daemon.Event{Kind: daemon.EventError, Path: changes[0].Path, Message: err.Error()})
+	}
+}
+
+// push sends one change and reports the outcome
+func (l *syncLoop) push(change watcher.FileChange) {
+	l.syncMu.Lock()
+	defer l.syncMu.Unlock()
+	if l.holdBack(change) {
+		return
+	}
+
+	err := pushChange(l.work, l.v, change)
+	switch {
+	case errors.Is(err, note.ErrUnsealed), errors.Is(err, hooks.ErrVetoed), errors.Is(err, secrets.ErrFound):
+		l.record(daemon.Event{Kind: daemon.EventSkip, Path: change.Path, Message: err.Error()})
+	case errors.Is(err, errConflict):
+		l.record(daemon.Event{Kind: daemon.EventConflict, Path: change.Path, Message: err.Error()})
+	case err != nil:
+		l.record(daemon.Event{Kind: daemon.EventError, Path: change.Path, Message: err.Error()})
+	default:
+		l.record(daemon.Event{Kind: daemon.EventPush, Path: change.Path})
+	}
+}
+
+// holdBack keeps changes for resume if the loop was paused after they
+// were picked up. It is called with syncMu held, which pause waits for,
+// so nothing is pushed once pause has returned. A change already pending
+// is newer and stays.
+func (l *syncLoop) holdBack(changes ...watcher.FileChange) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.paused {
+		return false
+	}
+	for _, change := range changes {
+		if _, ok := l.pending[change.Path]; !ok {
+			l.pending[change.Path] = change
+		}
+	}
+	return true
+}
+
+// startFeed follows the server's change feed in the background
+func (l *syncLoop) startFeed() {
+	ctx, cancel := context.WithCancel(l.ctx)
+	done := make(chan struct{})
+
+	l.mu.Lock()
+	l.feedCancel, l.feedDone = cancel, done
+	l.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		followRemoteChanges(ctx, l.v, func(written []string, err error) {
+			if errors.Is(err, errConflict) {
+				l.record(daemon.Event{Kind: daemon.EventConflict, Message: err.Error()})
+				return
+			}
+			if err != nil {
+				l.record(daemon.Event{Kind: daemon.EventError, Message: "following server changes: " + err.Error()})
+				return
+			}
+			for _, path := range written {
+				l.record(daemon.Event{Kind: daemon.EventPull, Path: path})
+			}
+		})
+	}()
+}
+
+// stopFeed stops the change feed and waits until it has let go of the
+// sync state
+func (l *syncLoop) stopFeed() {
+	l.mu.Lock()
+	cancel, done := l.feedCancel, l.feedDone
+	l.feedCancel, l.feedDone = nil, nil
+	l.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// pause holds back local changes and stops following the server. It
+// returns once nothing touches the notes or the sync state, so another
+// process may change them until resume. With a lease the loop resumes by
+// itself once it runs out; pausing again renews it, and pausing without
+// one holds the pause until resume.
+func (l *syncLoop) pause(lease time.Duration) {
+	l.ctlMu.Lock()
+	defer l.ctlMu.Unlock()
+
+	l.mu.Lock()
+	wasPaused := l.paused
+	renew := lease > 0 && (!wasPaused || l.lease != nil)
+	if l.lease != nil {
+		l.lease.Stop()
+		l.lease = nil
+	}
+	if renew {
+		// A fresh timer rather than Reset: one that already fired finds
+		// its lease replaced and leaves the pause alone
+		l.leaseGen++
+		gen := l.leaseGen
+		l.lease = time.AfterFunc(lease, func() { l.leaseExpired(gen) })
+	}
+	l.paused = true
+	l.mu.Unlock()
+	if wasPaused {
+		return
+	}
+
+	l.stopFeed()
+	l.syncMu.Lock()
+	l.syncMu.Unlock()
+	l.record(daemon.Event{Kind: daemon.EventPaused})
+}
+
+// leaseExpired resumes a pause whose client never resumed it, unless
+// lease gen was since renewed or ended
+func (l *syncLoop) leaseExpired(gen int) {
+	l.ctlMu.Lock()
+	defer l.ctlMu.Unlock()
+
+	l.mu.Lock()
+	current := l.lease != nil && l.leaseGen == gen
+	l.mu.Unlock()
+	if !current {
+		return
+	}
+
+	l.v.log.Warn("pause lease expired, resuming")
+	if err := l.unpause(); err != nil {
+		l.v.log.Error("failed to resume", "err", err)
+		l.record(daemon.Event{Kind: daemon.EventError, Message: err.Error()})
+	}
+}
+
+// resume picks up the sync state as saved on disk and pushes the changes
+// held back while paused
+func (l *syncLoop) resume() error {
+	l.ctlMu.Lock()
+	defer l.ctlMu.Unlock()
+	return l.unpause()
+}
+
+// unpause is resume with ctlMu held
+func (l *syncLoop) unpause() error {
+	l.mu.Lock()
+	if !l.paused {
+		l.mu.Unlock()
+		return nil
+	}
+	if l.lease != nil {
+		l.lease.Stop()
+		l.lease = nil
+	}
+	l.mu.Unlock()
+
+	if err := l.v.st.Reload(); err != nil {
+		return fmt.Errorf("failed to reload sync state: %w", err)
+	}
+
+	l.mu.Lock()
+	l.paused = false
+	pending := l.pending
+	l.pending = make(map[string]watcher.FileChange)
+	l.mu.Unlock()
+
+	l.record(daemon.Event{Kind: daemon.EventResumed})
+	l.startFeed()
+	batch := make([]watcher.FileChange, 0, len(pending))
+	for _, change := range pending {
+		batch = append(batch, change)
+	}
+	l.pushBatch(batch)
+	return nil
+}
+
+// syncNow pushes every note that changed since it was last synced and
+// pulls everything new from the server
+func (l *syncLoop) syncNow() error {
+	// Checked with syncMu held, like holdBack, so a pause can't slip in
+	l.syncMu.Lock()
+	defer l.syncMu.Unlock()
+
+	l.mu.Lock()
+	paused := l.paused
+	l.mu.Unlock()
+	if paused {
+		return fmt.Errorf("vault %s is paused", l.v.cfg.Name)
+	}
+
+	changes, err := l.w.ReadAllNotes()
+	if err != nil {
+		return err
+	}
+	pushed := 0
+	for _, change := range changes {
+		sent, err := sendChange(l.work, l.v, change)
+		if sent || err != nil {
+			l.v.jr.Log(journal.Push, change.Action, change.Path, journalResult(err), err)
+			logResult(l.v, "push", change.Action, change.Path, err)
+		}
+		if errors.Is(err, errConflict) {
+			l.record(daemon.Event{Kind: daemon.EventConflict, Path: change.Path, Message: err.Error()})
+		} else if err != nil {
+			l.record(daemon.Event{Kind: daemon.EventError, Path: change.Path, Message: err.Error()})
+		} else if sent {
+			pushed++
+			l.record(daemon.Event{Kind: daemon.EventPush, Path: change.Path})
+		}
+	}
+
+	resp, err := l.v.apiClient.Pull(l.work)
+	if err != nil {
+		return err
+	}
+	pulled := 0
+	for _, n := range resp.Changes {
+		written, err := pullNote(l.work, l.v, n)
+		switch {
+		case errors.Is(err, errConflict):
+			l.record(daemon.Event{Kind: daemon.EventConflict, Path: n.Path, Message: err.Error()})
+		case err != nil:
+			l.record(daemon.Event{Kind: daemon.EventError, Path: n.Path, Message: err.Error()})
+		case written:
+			pulled++
+			l.record(daemon.Event{Kind: daemon.EventPull, Path: n.Path})
+		}
+	}
+	if err := l.v.st.Save(); err != nil {
+		return fmt.Errorf("failed to save sync state: %w", err)
+	}
+	metrics.MarkSuccess(l.v.cfg.Name)
+
+	l.record(daemon.Event{Kind: daemon.EventSync, Message: fmt.Sprintf("pushed %d, pulled %d", pushed, pulled)})
+	return nil
+}
+
+// snapshot returns the loop's current status
+func (l *syncLoop) snapshot() daemon.VaultStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	st := l.status
+	st.Paused = l.paused
+	st.Pending = len(l.pending)
+	st.Conflicts = len(l.v.st.ConflictPaths())
+	return st
+}
+
+// record updates the status counters and passes the event on
+func (l *syncLoop) record(e daemon.Event) {
+	e.Time = time.Now()
+	e.Vault = l.v.cfg.Name
+
+	l.mu.Lock()
+	switch e.Kind {
+	case daemon.EventPush:
+		l.status.Pushed++
+		l.status.LastSync = e.Time
+	case daemon.EventPull:
+		l.status.Pulled++
+		l.status.LastSync = e.Time
+	case daemon.EventSync:
+		l.status.LastSync = e.Time
+	case daemon.EventError:
+		l.status.LastError = e.Message
+	}
+	l.mu.Unlock()
+
+	// Pushes and pulls are logged where they happen
+	switch e.Kind {
+	case daemon.EventPaused, daemon.EventResumed, daemon.EventSync:
+		l.v.log.Info(e.Kind, "message", e.Message)
+	}
+
+	l.emit(e)
+}
+
+// printEvent returns an emit function writing events to the console the
+// way -watch always has
+func printEvent(v *vault) func(daemon.Event) {
+	return func(e daemon.Event) {
+		switch e.Kind {
+		case daemon.EventPush:
+			v.printf("✓ Synced: %s\n", e.Path)
+		case daemon.EventPull:
+			v.printf("✓ Pulled: %s\n", e.Path)
+		case daemon.EventSkip:
+			v.printf("Skipping %s: %s\n", e.Path, e.Message)
+		case daemon.EventConflict:
+			v.printf("⚠ Conflict: %s (resolve in the TUI)\n", e.Message)
+		case daemon.EventError:
+			if e.Path != "" {
+				v.printf("Error syncing %s: %s\n", e.Path, e.Message)
+			} else {
+				v.printf("Error %s\n", e.Message)
+			}
+		case daemon.EventPaused:
+			v.printf("Paused\n")
+		case daemon.EventResumed:
+			v.printf("Resumed\n")
+		case daemon.EventSync:
+			v.printf("✓ Sync finished: %s\n", e.Message)
+		}
+	}
+}
@@ -1,22 +1,35 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
 
+	"github.com/daphen/notes-cli/internal/attachment"
 	"github.com/daphen/notes-cli/internal/client"
 	"github.com/daphen/notes-cli/internal/config"
+	"github.com/daphen/notes-cli/internal/hooks"
+	"github.com/daphen/notes-cli/internal/journal"
+	"github.com/daphen/notes-cli/internal/keyring"
+	"github.com/daphen/notes-cli/internal/metrics"
 	"github.com/daphen/notes-cli/internal/note"
+	"github.com/daphen/notes-cli/internal/syncer"
 	"github.com/daphen/notes-cli/internal/ui"
 	"github.com/daphen/notes-cli/internal/watcher"
 )
 
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
 func main() {
 	var (
 		configPath = flag.String("config", "", "Path to config file")
@@ -25,14 +38,41 @@ func main() {
 		pullCmd    = flag.Bool("pull", false, "Pull notes from server")
 		createCmd  = flag.Bool("create", false, "Quick note creation mode")
 		watchMode  = flag.Bool("watch", false, "Watch mode without TUI (background)")
+		vaultName  = flag.String("vault", "", "Vault to use (default: $NOTES_VAULT, default_vault, or all vaults with -watch)")
+		output     = flag.String("output", outputText, "Output of -push and -pull: text or json")
 	)
+	// -api-url, -notes-dir etc. override the config file and NOTES_* env
+	config.BindFlags(flag.CommandLine)
+
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintln(out, "Usage: notes-cli [flags] [command]")
+		fmt.Fprintln(out, "\nCommands:")
+		fmt.Fprintln(out, "  status [-json]   Show notes that differ between this machine and the server")
+		fmt.Fprintln(out, "  log [-n N] [-json] [filter...]")
+		fmt.Fprintln(out, "                   Show sync history, e.g. 'log pull error'")
+		fmt.Fprintln(out, "  config show [--resolved]")
+		fmt.Fprintln(out, "                   Print the config file, or every setting and where it came from")
+		fmt.Fprintln(out, "  config reference Print a config file documenting every option")
+		fmt.Fprintln(out, "  daemon [run]     Sync in the background, controlled over a local socket")
+		fmt.Fprintln(out, "  daemon status|pause|resume|sync|events [-json]")
+		fmt.Fprintln(out, "                   Query or drive the running daemon (-vault for one vault)")
+		fmt.Fprintln(out, "  service install [-env KEY=VALUE]|uninstall|status")
+		fmt.Fprintln(out, "                   Run the daemon at login as a systemd user service")
+		fmt.Fprintln(out, "\nFlags:")
+		flag.PrintDefaults()
+	}
 
 	flag.Parse()
+	if *output != outputText && *output != outputJSON {
+		fmt.Fprintf(os.Stderr, "Invalid -output %q (text, json)\n", *output)
+		os.Exit(2)
+	}
 
 	// Handle init command
 	if *initCmd {
 		if err := initConfig(); err != nil {
-			log.Fatal(err)
+			fatalf("%v", err)
 		}
 		return
 	}
@@ -43,57 +83,173 @@ func main() {
 		var err error
 		cfgPath, err = config.DefaultConfigPath()
 		if err != nil {
-			log.Fatalf("Failed to get config path: %v", err)
+			fatalf("Failed to get config path: %v", err)
 		}
 	}
 
 	cfg, err := config.Load(cfgPath)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v\nRun 'notes-cli -init' to create a config file.", err)
+		fatalf("Failed to load config: %v", err)
+	}
+	if *configPath != "" && cfg.Path == "" {
+		fatalf("Config file %s not found", *configPath)
+	}
+	for _, w := range cfg.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+
+	// Inspecting the config works even when it doesn't validate
+	if flag.Arg(0) == "config" {
+		if err := configCmd(cfg, *vaultName, flag.Args()[1:]); err != nil {
+			fatalf("Config failed: %v", err)
+		}
+		return
+	}
+
+	// Logging is shared by all vaults, so it's set up before one is chosen
+	globalCfg, err := cfg.Global(flag.CommandLine)
+	if err != nil {
+		fatalf("Invalid configuration:\n%v", err)
+	}
+	if err := setupLogging(globalCfg); err != nil {
+		fatalf("Failed to open log: %v", err)
+	}
+	defer closeLog()
+	logger.Debug("starting", "version", version, "args", os.Args[1:], "config", cfg.Path)
+	for _, w := range cfg.Warnings {
+		logger.Warn(w)
+	}
+
+	if flag.Arg(0) == "service" {
+		if err := serviceCmd(cfg, *vaultName, flag.Args()[1:]); err != nil {
+			fatalf("Service: %v", err)
+		}
+		return
+	}
+
+	// The daemon picks its own vaults; its other commands only need the socket
+	if flag.Arg(0) == "daemon" {
+		if err := daemonCmd(cfg, globalCfg.MetricsAddr, *vaultName, flag.Args()[1:]); err != nil {
+			fatalf("Daemon: %v", err)
+		}
+		return
+	}
+
+	// Without -vault, watch mode follows every vault at once
+	if *watchMode && *vaultName == "" && os.Getenv(config.VaultEnv) == "" &&
+		flag.NArg() == 0 && len(cfg.VaultNames()) > 1 {
+		if err := watchAll(cfg, globalCfg.MetricsAddr); err != nil {
+			fatalf("Watch failed: %v", err)
+		}
+		return
+	}
+
+	vaultCfg, err := cfg.Resolve(*vaultName, flag.CommandLine)
+	if err != nil {
+		if cfg.Path == "" {
+			fatalf("Invalid configuration:\n%v\nNo config file at %s - run 'notes-cli -init' to create one.", err, cfgPath)
+		}
+		fatalf("Invalid configuration:\n%v", err)
+	}
+
+	v, err := openVault(vaultCfg)
+	if err != nil {
+		fatalf("%v", err)
 	}
 
-	// Create API client
-	apiClient := client.New(cfg.APIURL, cfg.AuthPassword)
+	// The log is local - no need to reach the server
+	if flag.Arg(0) == "log" {
+		if err := logCmd(v.jr, flag.Args()[1:]); err != nil {
+			fatalf("Log failed: %v", err)
+		}
+		return
+	}
+
+	// Resolve the password and authenticate
+	if err := v.connect(); err != nil {
+		// Scripts get a report for -push and -pull failing to log in too
+		switch {
+		case *pushCmd:
+			finishSync(newSyncOutput(*output), "push", v, err)
+		case *pullCmd:
+			finishSync(newSyncOutput(*output), "pull", v, err)
+		}
+		fatalf("%v", err)
+	}
 
-	// Authenticate
-	if err := apiClient.Authenticate(); err != nil {
-		log.Fatalf("Authentication failed: %v", err)
+	// Handle subcommands
+	if flag.NArg() > 0 {
+		switch flag.Arg(0) {
+		case "status":
+			if err := statusCmd(v, flag.Args()[1:]); err != nil {
+				fatalf("Status failed: %v", err)
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown command: %s\n", flag.Arg(0))
+			flag.Usage()
+			os.Exit(2)
+		}
+		return
 	}
 
 	// Handle commands
 	if *pushCmd {
-		if err := pushNotes(cfg, apiClient); err != nil {
-			log.Fatalf("Push failed: %v", err)
-		}
+		out := newSyncOutput(*output)
+		ctx, release := signalContext()
+		err := pushNotes(ctx, v, out)
+		release()
+		finishSync(out, "push", v, err)
 		return
 	}
 
 	if *pullCmd {
-		if err := pullNotes(cfg, apiClient); err != nil {
-			log.Fatalf("Pull failed: %v", err)
-		}
+		out := newSyncOutput(*output)
+		ctx, release := signalContext()
+		err := pullNotes(ctx, v, out)
+		release()
+		finishSync(out, "pull", v, err)
 		return
 	}
 
 	if *createCmd {
 		// Quick create mode - start TUI in create view
-		if err := quickCreate(cfg, apiClient); err != nil {
-			log.Fatalf("Create failed: %v", err)
+		if err := quickCreate(v); err != nil {
+			fatalf("Create failed: %v", err)
 		}
 		return
 	}
 
 	if *watchMode {
 		// Background watch (no TUI)
-		if err := watchBackground(cfg, apiClient); err != nil {
-			log.Fatalf("Watch failed: %v", err)
+		// SIGTERM lets in-flight pushes finish before exiting
+		stopMetrics, err := serveMetrics(globalCfg.MetricsAddr)
+		if err != nil {
+			fatalf("Watch failed: %v", err)
+		}
+		defer stopMetrics()
+
+		ctx, release := signalContext()
+		defer release()
+		if err := watchBackground(ctx, v); err != nil {
+			fatalf("Watch failed: %v", err)
 		}
 		return
 	}
 
-	// Default: Start browse mode with TUI + background sync
-	if err := browseWithSync(cfg, apiClient); err != nil {
-		log.Fatalf("Browse failed: %v", err)
+	// Default: Start browse mode with TUI + background sync. Picking
+	// another vault in the TUI ends it and we reopen with that vault.
+	for {
+		next, err := browseWithSync(v, cfg.VaultNames())
+		if err != nil {
+			fatalf("Browse failed: %v", err)
+		}
+		if next == "" {
+			return
+		}
+
+		if v, err = connectVault(cfg, next); err != nil {
+			fatalf("Failed to open vault %s: %v", next, err)
+		}
 	}
 }
 
@@ -124,7 +280,8 @@ func initConfig() error {
 
 	// Interactive prompts
 	fmt.Println("\n📝 Notes CLI Configuration")
-	fmt.Println("==========================\n")
+	fmt.Println("==========================")
+	fmt.Println()
 
 	var apiURL, password, notesDir, clientID string
 
@@ -134,31 +291,53 @@ func initConfig() error {
 		apiURL = "https://notes-sigma-tawny.vercel.app"
 	}
 
-	fmt.Print("Auth password: ")
-	fmt.Scanln(&password)
+	password, err = readPassword("Auth password: ")
+	if err != nil {
+		return err
+	}
 	if password == "" {
 		return fmt.Errorf("password is required")
 	}
 
+	// Prefer the keyring over a plaintext password in the config file
+	authLine := fmt.Sprintf("auth_password = %q", password)
+	if keyring.Available() {
+		fmt.Print("Store the password in the system keyring instead of the config file? (Y/n): ")
+		var response string
+		fmt.Scanln(&response)
+
+		if response != "n" && response != "N" {
+			if err := keyring.Store(config.DefaultVaultName, password); err != nil {
+				return err
+			}
+			authLine = "auth_keyring = true"
+			fmt.Println("✓ Password stored in the keyring")
+		}
+	}
+
 	fmt.Print("Notes directory [~/personal/notes/storage]: ")
 	fmt.Scanln(&notesDir)
 	if notesDir == "" {
 		notesDir = "~/personal/notes/storage"
 	}
 
-	fmt.Print("Client ID [notes-cli-go]: ")
+	// Left empty, a device ID generated on first run is used
+	fmt.Print("Client ID [generated device ID]: ")
 	fmt.Scanln(&clientID)
-	if clientID == "" {
-		clientID = "notes-cli-go"
+	clientLine := "# client_id = \"laptop\"   # default: generated device ID"
+	if clientID != "" {
+		clientLine = fmt.Sprintf("client_id = %q", clientID)
 	}
 
 	// Generate config content
 	configContent := fmt.Sprintf(`# Notes CLI Configuration
-api_url = "%s"
-auth_password = "%s"
-notes_dir = "%s"
-client_id = "%s"
-`, apiURL, password, notesDir, clientID)
+# Run 'notes-cli config reference' to see every option.
+version = %d
+api_url = %q
+%s
+notes_dir = %q
+%s
+`, config.CurrentVersion, apiURL, authLine, notesDir, clientLine)
 
 	// Write config file with secure permissions
 	if err := os.WriteFile(cfgPath, []byte(configContent), 0600); err != nil {
@@ -170,252 +349,695 @@ client_id = "%s"
 	return nil
 }
 
-func pushNotes(cfg *config.Config, apiClient *client.Client) error {
-	w, err := watcher.New(cfg.NotesDir)
+// readPassword prompts for a password without echoing it. Input that isn't
+// a terminal (e.g. a pipe) is read as a plain line.
+func readPassword(prompt string) (string, error) {
+	fmt.Print(prompt)
+
+	fd := os.Stdin.Fd()
+	if term.IsTerminal(fd) {
+		pw, err := term.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		return string(pw), nil
+	}
+
+	// Read byte by byte so later fmt.Scanln prompts still see their lines
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if n == 0 || err != nil || buf[0] == '\n' {
+			break
+		}
+		line = append(line, buf[0])
+	}
+	return strings.TrimRight(string(line), "\r"), nil
+}
+
+// pushNotes pushes every note in the vault. Once ctx is done (Ctrl+C) no
+// further batches are sent; the notes the server accepted until then are
+// still recorded in the sync state.
+func pushNotes(ctx context.Context, v *vault, out *syncOutput) error {
+	w, err := v.newWatcher()
 	if err != nil {
 		return err
 	}
 	defer w.Close()
 
-	fmt.Println("Reading all notes...")
-	changes, err := w.ReadAllNotes()
+	changes, err := w.ReadAllNotesProgress(ctx, func(notes int, bytes int64) {
+		out.progress("Reading notes... %d (%s)", notes, formatBytes(bytes))
+	})
+	out.done()
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w before anything was pushed", errInterrupted)
+	}
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Found %d notes to push\n", len(changes))
+	out.printf("Found %d notes to push\n", len(changes))
 
 	// Process each note with business logic (title extraction, checksum, etc.)
-	notes := make([]client.Note, len(changes))
+	notes := make([]client.Note, 0, len(changes))
+	var total int64
+	skipped, heldBack := 0, 0
 	for i, change := range changes {
-		processed := note.ProcessNote(change.Path, change.Content, "update")
-		notes[i] = client.Note{
-			Path:     processed.Path,
-			Title:    processed.Title,
-			Content:  processed.Content,
-			Checksum: processed.Checksum,
-			Action:   processed.Action,
+		if err := ctx.Err(); err != nil {
+			out.done()
+			return fmt.Errorf("%w before anything was pushed", errInterrupted)
+		}
+		out.progress("Checking notes... %d/%d", i+1, len(changes))
+
+		// Pushing would overwrite the server's side of the conflict
+		if err := checkConflict(v, change.Path); err != nil {
+			out.printf("  ⚠ Skipping %s: unresolved conflict\n", change.Path)
+			out.result("conflict", change.Path, err)
+			v.jr.Log(journal.Push, "update", change.Path, journal.Conflict, err)
+			logResult(v, "push", "update", change.Path, err)
+			heldBack++
+			continue
+		}
+
+		n, err := buildNote(change.Path, change.Content, "update")
+		if err == nil {
+			n, err = prePush(ctx, v, n)
+		}
+		if err == nil {
+			n, err = checkSecrets(v, n)
+		}
+		if err != nil {
+			out.printf("  ⚠ Skipping %s: %v\n", change.Path, err)
+			out.result("skipped", change.Path, err)
+			v.jr.Log(journal.Push, "update", change.Path, journalResult(err), err)
+			logResult(v, "push", "update", change.Path, err)
+			skipped++
+			continue
 		}
+		notes = append(notes, n)
+		total += int64(len(n.Content))
 	}
+	out.done()
 
-	fmt.Println("Pushing to server...")
-	resp, err := apiClient.Push(notes)
+	batchBytes, err := v.apiClient.ChunkLimit(ctx)
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w before anything was pushed", errInterrupted)
+	}
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Sent %d notes, server accepted %d\n", len(notes), len(resp.Accepted))
+	out.printf("Pushing %s to server (%d workers)...\n", formatBytes(total), v.cfg.PushWorkers)
+	// Progress is called from the pool's workers
+	var mu sync.Mutex
+	sentNotes, batches := 0, 0
+	var sent int64
+	start := time.Now()
+	pool := syncer.New(v.apiClient, syncer.Options{
+		Workers:           v.cfg.PushWorkers,
+		RequestsPerSecond: v.cfg.PushRateLimit,
+		BatchBytes:        batchBytes,
+		MaxRetries:        5,
+		Progress: func(b syncer.BatchResult) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			batches++
+			if b.Err == nil {
+				sentNotes += b.Notes
+				sent += b.Bytes
+				metrics.Bytes.Add(float64(b.Bytes), v.cfg.Name, "push")
+			} else if ctx.Err() == nil {
+				out.printf("  ✗ Batch of %d notes failed: %v\n", b.Notes, b.Err)
+			}
+			out.progress("Pushed %d/%d notes · %s of %s · %d batches%s",
+				sentNotes, len(notes), formatBytes(sent), formatBytes(total), batches, eta(start, sent, total))
+		},
+	})
+	report := pool.PushAll(ctx, notes)
+	out.done()
+
+	// Notes left unsent by Ctrl+C didn't fail, they're sent next time
+	var failed []syncer.Failure
+	unsent := 0
+	for _, f := range report.Failed {
+		if ctx.Err() != nil && errors.Is(f.Err, ctx.Err()) {
+			unsent++
+			out.result("unsent", f.Path, nil)
+			continue
+		}
+		failed = append(failed, f)
+	}
 
-	if len(resp.Accepted) > 0 {
-		fmt.Println("\n✓ Accepted:")
-		for _, path := range resp.Accepted {
-			fmt.Printf("  • %s\n", path)
+	out.printf("Sent %d notes in %d batches, server accepted %d\n", len(notes)-unsent, report.Batches, len(report.Accepted))
+	if report.Retries > 0 {
+		out.printf("Retried %d times after rate limiting\n", report.Retries)
+	}
+
+	if len(report.Accepted) > 0 {
+		out.printf("\n✓ Accepted:\n")
+		for _, path := range report.Accepted {
+			out.printf("  • %s\n", path)
 		}
 	}
 
-	if len(resp.Conflicts) > 0 {
-		fmt.Println("\n⚠ Conflicts:")
-		for _, path := range resp.Conflicts {
-			fmt.Printf("  • %s\n", path)
+	if len(report.Conflicts) > 0 {
+		out.printf("\n⚠ Conflicts:\n")
+		for _, path := range report.Conflicts {
+			out.printf("  • %s\n", path)
 		}
 	}
 
-	if len(resp.Accepted) != len(notes) {
-		fmt.Printf("\n⚠ WARNING: Sent %d notes but only %d were accepted!\n", len(notes), len(resp.Accepted))
-		return fmt.Errorf("incomplete sync: expected %d accepted, got %d", len(notes), len(resp.Accepted))
+	if len(failed) > 0 {
+		out.printf("\n✗ Failed:\n")
+		for _, f := range failed {
+			out.printf("  • %s: %v\n", f.Path, f.Err)
+		}
+	}
+
+	byPath := make(map[string]client.Note, len(notes))
+	for _, n := range notes {
+		byPath[n.Path] = n
+	}
+	var accepted int64
+	for _, path := range report.Accepted {
+		v.st.Record(path, byPath[path].Checksum, "")
+		v.jr.Log(journal.Push, "update", path, journal.OK, nil)
+		logResult(v, "push", "update", path, nil)
+		out.result("accepted", path, nil)
+		accepted += int64(len(byPath[path].Content))
+	}
+	for _, path := range report.Conflicts {
+		v.jr.Log(journal.Push, "update", path, journal.Conflict, nil)
+		logResult(v, "push", "update", path, errConflict)
+		v.hooks.Run(ctx, hooks.OnConflict, v.hookNote(path, byPath[path].Title, "conflict"))
+		out.result("conflict", path, nil)
+	}
+	for _, f := range failed {
+		v.jr.Log(journal.Push, "update", f.Path, journal.Failed, f.Err)
+		logResult(v, "push", "update", f.Path, f.Err)
+		out.result("failed", f.Path, f.Err)
+	}
+	if err := v.st.Save(); err != nil {
+		return fmt.Errorf("failed to save sync state: %w", err)
+	}
+
+	attachments := 0
+	var attErr error
+	if ctx.Err() == nil {
+		attachments, attErr = pushAttachments(ctx, v, changes, out)
 	}
 
-	fmt.Printf("\n✓ Successfully synced all %d notes\n", len(notes))
+	out.summary("notes", len(changes), "accepted", len(report.Accepted), "conflicts", len(report.Conflicts)+heldBack,
+		"skipped", skipped, "failed", len(failed), "unsent", unsent,
+		"batches", report.Batches, "bytes", accepted, "attachments", attachments)
+
+	if ctx.Err() != nil {
+		out.printf("\n⚠ Interrupted: %d notes not sent, push again to send them\n", unsent)
+		return fmt.Errorf("%w after %d of %d notes were accepted", errInterrupted, len(report.Accepted), len(notes))
+	}
+	if attErr != nil {
+		return attErr
+	}
+
+	// Nothing got through: report why, so the exit status and error code
+	// say server or network rather than incomplete
+	if len(report.Accepted) == 0 && len(failed) > 0 {
+		out.printf("\n✗ No notes were pushed\n")
+		return fmt.Errorf("%d notes failed to push: %w", len(failed), failed[0].Err)
+	}
+
+	// Anything neither accepted nor conflicting wasn't pushed
+	missing := len(changes) - len(report.Accepted) - len(report.Conflicts) - heldBack
+	if missing > 0 {
+		out.printf("\n⚠ WARNING: %d of %d notes were not pushed\n", missing, len(changes))
+		return fmt.Errorf("%w (%d of %d not pushed)", errIncomplete, missing, len(changes))
+	}
+	if conflicts := len(report.Conflicts) + heldBack; conflicts > 0 {
+		out.printf("\n⚠ %d conflicts - open notes-cli and press Ctrl+S to resolve them\n", conflicts)
+		return fmt.Errorf("%d notes %w", conflicts, errConflict)
+	}
+
+	out.printf("\n✓ Successfully synced all %d notes\n", len(notes))
 	return nil
 }
 
-func pullNotes(cfg *config.Config, apiClient *client.Client) error {
-	fmt.Println("Pulling notes from server...")
-	resp, err := apiClient.Pull()
+// pullNotes pulls every note from the server. Once ctx is done (Ctrl+C)
+// it stops between notes; the ones already written are recorded.
+func pullNotes(ctx context.Context, v *vault, out *syncOutput) error {
+	out.printf("Pulling notes from server...\n")
+	resp, err := v.apiClient.PullProgress(ctx, func(received int64) {
+		out.progress("Downloading... %s", formatBytes(received))
+	})
+	out.done()
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w before anything was pulled", errInterrupted)
+	}
 	if err != nil {
 		return err
 	}
 
 	if len(resp.Changes) == 0 {
-		fmt.Println("No changes to pull")
-		return nil
+		out.printf("No changes to pull\n")
+	} else {
+		out.printf("Received %d notes\n", len(resp.Changes))
 	}
 
-	fmt.Printf("Received %d notes\n", len(resp.Changes))
-
-	for _, n := range resp.Changes {
-		fullPath := filepath.Join(cfg.NotesDir, n.Path)
+	written, unchanged, conflicts, attachments := 0, 0, 0, 0
+	summarize := func() {
+		out.summary("notes", len(resp.Changes), "written", written, "unchanged", unchanged,
+			"conflicts", conflicts, "attachments", attachments)
+	}
 
-		dir := filepath.Dir(fullPath)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("failed to create directory: %w", err)
+	var pullErr error
+	for i, n := range resp.Changes {
+		if ctx.Err() != nil {
+			pullErr = fmt.Errorf("%w after %d of %d notes", errInterrupted, i, len(resp.Changes))
+			break
 		}
-
-		if err := os.WriteFile(fullPath, []byte(n.Content), 0644); err != nil {
-			return fmt.Errorf("failed to write %s: %w", n.Path, err)
+		out.progress("Applying notes... %d/%d", i+1, len(resp.Changes))
+
+		ok, err := pullNote(ctx, v, n)
+		if errors.Is(err, errConflict) {
+			out.printf("  ⚠ %s: changed locally and on the server, kept local version\n", n.Path)
+			out.result("conflict", n.Path, nil)
+			conflicts++
+			continue
 		}
-
-		// Preserve server's modification time
-		if n.UpdatedAt != "" {
-			if modTime, err := time.Parse(time.RFC3339, n.UpdatedAt); err == nil {
-				os.Chtimes(fullPath, modTime, modTime)
-			}
+		if err != nil {
+			out.result("failed", n.Path, err)
+			pullErr = err
+			break
+		}
+		if !ok {
+			unchanged++
+			continue
 		}
+		written++
+		out.printf("  ✓ %s\n", n.Path)
+		if n.DeletedAt != "" {
+			out.result("deleted", n.Path, nil)
+		} else {
+			out.result("written", n.Path, nil)
+		}
+	}
+	out.done()
+
+	if unchanged > 0 {
+		out.printf("%d notes already up to date\n", unchanged)
+	}
+	if conflicts > 0 {
+		out.printf("%d conflicts - open notes-cli and press Ctrl+S to resolve them\n", conflicts)
+	}
+
+	// Notes written before an error or Ctrl+C are kept
+	if err := v.st.Save(); err != nil {
+		return fmt.Errorf("failed to save sync state: %w", err)
+	}
+	if pullErr != nil {
+		summarize()
+		return pullErr
+	}
+	metrics.MarkSuccess(v.cfg.Name)
 
-		fmt.Printf("  ✓ %s\n", n.Path)
+	attReport, err := syncer.PullAttachments(ctx, v.apiClient, v.cfg.NotesDir)
+	if err == nil {
+		err = recordAttachments(v, attReport.Synced)
+	}
+	if err != nil {
+		summarize()
+		return fmt.Errorf("attachments: %w", err)
 	}
+	if len(attReport.Downloaded) > 0 {
+		out.printf("Downloaded %d attachments (%s)\n", len(attReport.Downloaded), formatBytes(attReport.Bytes))
+		for _, path := range attReport.Downloaded {
+			out.printf("  ✓ %s\n", path)
+			out.result("attachment", path, nil)
+			v.jr.Log(journal.Pull, "attachment", path, journal.OK, nil)
+		}
+	}
+	attachments = len(attReport.Downloaded)
 
+	summarize()
+	if conflicts > 0 {
+		return fmt.Errorf("%d notes %w", conflicts, errConflict)
+	}
 	return nil
 }
 
-func quickCreate(cfg *config.Config, apiClient *client.Client) error {
+// pushAttachments uploads images and other files referenced from the notes
+// or stored under the attachments folder
+func pushAttachments(ctx context.Context, v *vault, changes []watcher.FileChange, out *syncOutput) (int, error) {
+	contents := make(map[string]string, len(changes))
+	for _, change := range changes {
+		contents[change.Path] = change.Content
+	}
+
+	atts, err := attachment.Scan(v.cfg.NotesDir, contents)
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan attachments: %w", err)
+	}
+	if len(atts) == 0 {
+		return 0, nil
+	}
+
+	out.printf("\nSyncing %d attachments...\n", len(atts))
+	attReport, err := syncer.PushAttachments(ctx, v.apiClient, v.cfg.NotesDir, atts)
+	if errors.Is(err, client.ErrNoAttachments) {
+		out.printf("  ⚠ Server does not support attachments, skipped\n")
+		return 0, nil
+	}
+	if err == nil {
+		err = recordAttachments(v, attReport.Synced)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("attachments: %w", err)
+	}
+
+	for _, path := range attReport.Uploaded {
+		out.printf("  ✓ %s\n", path)
+		out.result("attachment", path, nil)
+		v.jr.Log(journal.Push, "attachment", path, journal.OK, nil)
+	}
+	out.printf("Uploaded %d attachments (%s), %d already on server\n",
+		len(attReport.Uploaded), formatBytes(attReport.Bytes), attReport.Skipped)
+	return len(attReport.Uploaded), nil
+}
+
+func quickCreate(v *vault) error {
 	// Start TUI in create mode
-	model := ui.NewModel(cfg.NotesDir)
+	model := ui.NewModel(v.cfg.NotesDir)
 	model.SetCreateView() // Switch to create view immediately
+	// A running daemon already syncs the vault
+	ctl, daemonName := daemonFor(v)
+	if ctl != nil {
+		defer ctl.Close()
+	}
+
+	model.SetConflictResolver(&conflictResolver{v: v, ctl: ctl, daemonName: daemonName})
+	model.SetJournal(v.jr)
 
 	p := tea.NewProgram(model, tea.WithAltScreen())
 	model.SetProgram(p)
 
-	// Start background sync
-	go backgroundSync(cfg, apiClient, p)
+	// Start background sync, cancelled when the TUI exits
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if ctl != nil {
+			followDaemon(ctx, daemonName, p)
+		} else {
+			backgroundSync(ctx, v, p)
+		}
+	}()
 
-	if _, err := p.Run(); err != nil {
+	_, err := p.Run()
+	cancel()
+	waitSyncs(&wg)
+	if err != nil {
 		return fmt.Errorf("TUI error: %w", err)
 	}
 
+	return saveState(v)
+}
+
+// saveState flushes the sync state once syncing has stopped
+func saveState(v *vault) error {
+	if err := v.st.Save(); err != nil {
+		return fmt.Errorf("failed to save sync state: %w", err)
+	}
 	return nil
 }
 
-func browseWithSync(cfg *config.Config, apiClient *client.Client) error {
+// browseWithSync runs the TUI for one vault. It returns the name of the
+// vault picked in the switcher, or "" when the user quit.
+func browseWithSync(v *vault, vaults []string) (string, error) {
 	// Create the TUI model
-	model := ui.NewModel(cfg.NotesDir)
+	model := ui.NewModel(v.cfg.NotesDir)
+	model.SetJournal(v.jr)
+	model.SetVaults(vaults, v.cfg.Name)
+
+	// A running daemon already syncs the vault: show its activity instead
+	// of starting a second watcher
+	ctl, daemonName := daemonFor(v)
+	if ctl != nil {
+		defer ctl.Close()
+	}
+	model.SetConflictResolver(&conflictResolver{v: v, ctl: ctl, daemonName: daemonName})
 
 	// Create the program with alt screen (full terminal takeover)
 	p := tea.NewProgram(model, tea.WithAltScreen())
 	model.SetProgram(p)
 
+	// Cancelled when the TUI exits to stop the change feed and watcher.
+	// The sync goroutines are waited for before returning, so nothing is
+	// cut off mid-push.
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+
 	// Start initial sync + background watcher in goroutine
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
+		if ctl != nil {
+			followDaemon(ctx, daemonName, p)
+			return
+		}
+
 		// Signal sync starting
 		p.Send(ui.SendSyncStart())
 
 		// Pull from server first to get any remote changes
-		resp, err := apiClient.Pull()
+		resp, err := v.apiClient.Pull(ctx)
 		if err != nil {
 			p.Send(ui.SendSyncError(err))
-		} else if len(resp.Changes) > 0 {
+		} else {
+			// Follow the change feed from this snapshot on
+			v.st.SetCursor(resp.Timestamp)
+		}
+		if err == nil && len(resp.Changes) > 0 {
 			// Apply remote changes to local files
+			written := 0
 			for _, n := range resp.Changes {
-				fullPath := filepath.Join(cfg.NotesDir, n.Path)
-				dir := filepath.Dir(fullPath)
-				if err := os.MkdirAll(dir, 0755); err != nil {
+				ok, err := pullNote(ctx, v, n)
+				if errors.Is(err, errConflict) {
+					p.Send(ui.SendConflictsChanged())
 					continue
 				}
-				if err := os.WriteFile(fullPath, []byte(n.Content), 0644); err != nil {
+				if err != nil {
+					p.Send(ui.SendSyncError(err))
 					continue
 				}
-				// Preserve server's modification time
-				if n.UpdatedAt != "" {
-					if modTime, err := time.Parse(time.RFC3339, n.UpdatedAt); err == nil {
-						os.Chtimes(fullPath, modTime, modTime)
-					}
+				if ok {
+					written++
 				}
 			}
-			p.Send(ui.SendSyncSuccess(fmt.Sprintf("%d notes from server", len(resp.Changes))))
+			if err := v.st.Save(); err != nil {
+				p.Send(ui.SendSyncError(err))
+			}
+			if written > 0 {
+				p.Send(ui.SendSyncSuccess(fmt.Sprintf("%d notes from server", written)))
+			}
+		}
+
+		attReport, err := syncer.PullAttachments(ctx, v.apiClient, v.cfg.NotesDir)
+		if err == nil {
+			err = recordAttachments(v, attReport.Synced)
+		}
+		if err != nil {
+			p.Send(ui.SendSyncError(fmt.Errorf("attachments: %w", err)))
+		} else if len(attReport.Downloaded) > 0 {
+			for _, path := range attReport.Downloaded {
+				v.jr.Log(journal.Pull, "attachment", path, journal.OK, nil)
+			}
+			p.Send(ui.SendSyncSuccess(fmt.Sprintf("%d attachments from server", len(attReport.Downloaded))))
 		}
 
 		// Signal sync complete
 		p.Send(ui.SendSyncEnd())
 
+		// Stream remote changes while the TUI is open
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			followRemoteChanges(ctx, v, func(written []string, err error) {
+				if errors.Is(err, errConflict) {
+					p.Send(ui.SendConflictsChanged())
+					return
+				}
+				if err != nil {
+					p.Send(ui.SendSyncError(err))
+					return
+				}
+				if len(written) > 0 {
+					p.Send(ui.SendSyncSuccess(fmt.Sprintf("%d notes from server", len(written))))
+					p.Send(ui.SendNotesChanged())
+				}
+			})
+		}()
+
 		// Now start watching for file changes
-		backgroundSync(cfg, apiClient, p)
+		backgroundSync(ctx, v, p)
 	}()
 
 	// Run the TUI (blocks until quit)
-	if _, err := p.Run(); err != nil {
-		return fmt.Errorf("TUI error: %w", err)
+	final, err := p.Run()
+	cancel()
+	waitSyncs(&wg)
+	if err != nil {
+		return "", fmt.Errorf("TUI error: %w", err)
+	}
+	if err := saveState(v); err != nil {
+		return "", err
 	}
 
-	return nil
+	if m, ok := final.(ui.Model); ok {
+		return m.SwitchTo(), nil
+	}
+	return "", nil
 }
 
-func watchBackground(cfg *config.Config, apiClient *client.Client) error {
-	// Create file watcher
-	w, err := watcher.New(cfg.NotesDir)
+// watchBackground syncs one vault without a TUI until ctx is done
+func watchBackground(ctx context.Context, v *vault) error {
+	loop, err := newSyncLoop(ctx, v, printEvent(v))
 	if err != nil {
 		return err
 	}
-	defer w.Close()
 
-	fmt.Println("Watching for changes...")
-	changes := w.Watch()
+	v.printf("Watching %s for changes%s...\n", v.cfg.NotesDir, watching(loop.w))
+	loop.run()
+	return nil
+}
 
-	for change := range changes {
-		fmt.Printf("Detected change: %s\n", change.Path)
+// watchAll runs a watch loop for every configured vault concurrently.
+// A vault that fails to start is reported and the others keep running.
+func watchAll(cfg *config.Config, metricsAddr string) error {
+	var wg sync.WaitGroup
+	started := 0
 
-		// Process the note with business logic
-		processed := note.ProcessNote(change.Path, change.Content, change.Action)
+	if err := config.CheckOverrides(flag.CommandLine); err != nil {
+		return err
+	}
+	if err := cfg.CheckVaultDirs(flag.CommandLine); err != nil {
+		return err
+	}
 
-		notes := []client.Note{{
-			Path:     processed.Path,
-			Title:    processed.Title,
-			Content:  processed.Content,
-			Checksum: processed.Checksum,
-			Action:   processed.Action,
-		}}
+	stopMetrics, err := serveMetrics(metricsAddr)
+	if err != nil {
+		return err
+	}
+	defer stopMetrics()
 
-		_, err := apiClient.Push(notes)
+	ctx, release := signalContext()
+	defer release()
+
+	for _, name := range cfg.VaultNames() {
+		v, err := connectVault(cfg, name)
 		if err != nil {
-			fmt.Printf("Error syncing: %v\n", err)
-		} else {
-			fmt.Printf("✓ Synced: %s\n", change.Path)
+			fmt.Printf("[%s] Skipping vault: %v\n", name, err)
+			logger.Error("skipping vault", "vault", name, "err", err)
+			continue
 		}
+		v.tag = name
+		started++
+
+		// 🔵 GO CONCEPT: sync.WaitGroup
+		// Add before starting each goroutine, Done when it ends, and Wait
+		// blocks until the counter is back to zero.
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := watchBackground(ctx, v); err != nil {
+				v.printf("Watch failed: %v\n", err)
+			}
+		}()
+	}
 
-		time.Sleep(100 * time.Millisecond)
+	if started == 0 {
+		return fmt.Errorf("no vault could be started")
 	}
 
+	wg.Wait()
 	return nil
 }
 
-func backgroundSync(cfg *config.Config, apiClient *client.Client, p *tea.Program) {
+// backgroundSync pushes local changes while the TUI is open, until ctx is
+// done. Changes still queued by then are pushed within shutdownGrace.
+func backgroundSync(ctx context.Context, v *vault, p *tea.Program) {
+	work, cancel := withGrace(ctx, shutdownGrace)
+	defer cancel()
+
 	// Create file watcher
-	w, err := watcher.New(cfg.NotesDir)
+	w, err := v.newWatcher()
 	if err != nil {
 		p.Send(ui.SendSyncError(err))
 		return
 	}
 	defer w.Close()
 
-	p.Send(ui.SendSyncStatus("Watching for changes..."))
-
-	changes := w.Watch()
+	p.Send(ui.SendSyncStatus("Watching for changes" + watching(w) + "..."))
 
+	changes := w.Watch(ctx)
 	for change := range changes {
-		// Signal sync starting
-		p.Send(ui.SendSyncStart())
+		// Out of time: left for the next sync to pick up
+		if work.Err() != nil {
+			break
+		}
 
-		// Process the note with business logic
-		processed := note.ProcessNote(change.Path, change.Content, change.Action)
+		// Attachments queued together are sent in one batch
+		if change.Attachment {
+			batch := []watcher.FileChange{change}
+			for _, queued := range watcher.Queued(changes) {
+				if queued.Attachment {
+					batch = append(batch, queued)
+				} else {
+					pushFromTUI(work, v, p, queued)
+				}
+			}
 
-		// Sync this change to the server
-		notes := []client.Note{{
-			Path:     processed.Path,
-			Title:    processed.Title,
-			Content:  processed.Content,
-			Checksum: processed.Checksum,
-			Action:   processed.Action,
-		}}
+			p.Send(ui.SendSyncStart())
+			sent, err := pushAttachmentChanges(work, v, batch)
+			if err != nil {
+				p.Send(ui.SendSyncError(err))
+			} else if len(sent) > 0 {
+				p.Send(ui.SendSyncSuccess(fmt.Sprintf("%d attachments", len(sent))))
+			}
+			p.Send(ui.SendSyncEnd())
+			continue
+		}
 
-		_, err := apiClient.Push(notes)
-		if err != nil {
-			p.Send(ui.SendSyncError(err))
-		} else {
-			p.Send(ui.SendSyncSuccess(change.Path))
+		pushFromTUI(work, v, p, change)
+		if ctx.Err() == nil {
+			time.Sleep(100 * time.Millisecond)
 		}
+	}
+}
 
-		// Signal sync complete
-		p.Send(ui.SendSyncEnd())
+// pushFromTUI pushes one note change from backgroundSync, showing the
+// outcome in the TUI
+func pushFromTUI(ctx context.Context, v *vault, p *tea.Program, change watcher.FileChange) {
+	// Refuse locally before showing a sync in progress
+	if err := note.CheckSyncable(change.Content); err != nil {
+		v.jr.Log(journal.Push, change.Action, change.Path, journal.Skipped, err)
+		logResult(v, "push", change.Action, change.Path, err)
+		p.Send(ui.SendSyncError(fmt.Errorf("%s: %w", change.Path, err)))
+		return
+	}
 
-		time.Sleep(100 * time.Millisecond)
+	// Signal sync starting
+	p.Send(ui.SendSyncStart())
+
+	// Sync this change to the server
+	if err := pushChange(ctx, v, change); err != nil {
+		p.Send(ui.SendSyncError(err))
+	} else {
+		p.Send(ui.SendSyncSuccess(change.Path))
 	}
+
+	// Signal sync complete
+	p.Send(ui.SendSyncEnd())
 }
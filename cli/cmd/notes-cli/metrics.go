@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/daphen/notes-cli/internal/metrics"
+)
+
+// serveMetrics serves Prometheus metrics at http://addr/metrics until the
+// returned function is called. An empty addr serves nothing.
+func serveMetrics(addr string) (stop func(), err error) {
+	if addr == "" {
+		return func() {}, nil
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("metrics: %w", err)
+	}
+	metrics.BuildInfo.Set(1, version)
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Default.Handler())
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(ln)
+
+	logger.Info("serving metrics", "addr", "http://"+ln.Addr().String()+"/metrics")
+	return func() { srv.Close() }, nil
+}
+
+// failureType folds errorCode into the types of notes_sync_failures_total
+func failureType(err error) string {
+	switch code := errorCode(err); code {
+	case "auth", "rate_limited", "client", "server", "network":
+		return code
+	case "too_large":
+		return "client"
+	case "unavailable":
+		return "server"
+	case "timeout":
+		return "network"
+	}
+	// Unreadable files, failed writes, refused notes
+	return "local"
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+
+	"github.com/daphen/notes-cli/internal/client"
+	"github.com/daphen/notes-cli/internal/hooks"
+	"github.com/daphen/notes-cli/internal/note"
+	"github.com/daphen/notes-cli/internal/secrets"
+)
+
+// Formats for -output
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// Exit statuses of -push and -pull, so scripts can tell outcomes apart.
+// Anything else that stops notes-cli exits 1, or 2 for bad usage.
+const (
+	exitFailed      = 1   // Stopped by an error (server, network, auth, disk)
+	exitConflicts   = 3   // Everything else synced, but some notes conflict
+	exitIncomplete  = 4   // Some notes were skipped (e.g. secrets) or failed
+	exitInterrupted = 130 // Ctrl+C, reported the way shells do (128 + SIGINT)
+)
+
+// errIncomplete is returned when some notes were skipped or failed while
+// the rest synced
+var errIncomplete = errors.New("some notes were skipped or failed")
+
+// syncOutput prints what -push and -pull are doing. On a terminal that's
+// text for people plus a progress line redrawn in place; otherwise only
+// one tab-separated line per note and a summary, for scripts:
+//
+//	accepted	ideas.md
+//	skipped	keys.md	note contains a possible secret: ...
+//	summary	notes=2 accepted=1 skipped=1 ...
+//
+// With -output json nothing is printed until finish writes a syncReport.
+type syncOutput struct {
+	tty    bool
+	report *syncReport // -output json only
+
+	mu    sync.Mutex
+	line  string    // Progress line currently shown
+	drawn time.Time // When it was last drawn
+}
+
+// syncReport is the -output json result of -push and -pull. Lists are
+// always present, empty if nothing ended up in them.
+type syncReport struct {
+	Command     string         `json:"command"` // "push" or "pull"
+	Vault       string         `json:"vault"`
+	OK          bool           `json:"ok"`
+	ExitCode    int            `json:"exitCode"`
+	Error       *syncIssue     `json:"error,omitempty"` // Why not everything synced
+	Summary     map[string]any `json:"summary"`         // Counts, as in the summary line
+	Accepted    []string       `json:"accepted"`
+	Written     []string       `json:"written"`
+	Deleted     []string       `json:"deleted"`
+	Conflicts   []string       `json:"conflicts"`
+	Unsent      []string       `json:"unsent"` // Left for the next push by Ctrl+C
+	Attachments []string       `json:"attachments"`
+	Skipped     []syncIssue    `json:"skipped"`
+	Errors      []syncIssue    `json:"errors"` // Notes that failed
+}
+
+// syncIssue is a note that didn't sync, or the error that stopped a sync
+type syncIssue struct {
+	Path    string `json:"path,omitempty"`
+	Code    string `json:"code"` // See errorCode
+	Message string `json:"message"`
+}
+
+func newSyncOutput(format string) *syncOutput {
+	if format == outputJSON {
+		return &syncOutput{report: &syncReport{
+			Summary:     map[string]any{},
+			Accepted:    []string{},
+			Written:     []string{},
+			Deleted:     []string{},
+			Conflicts:   []string{},
+			Unsent:      []string{},
+			Attachments: []string{},
+			Skipped:     []syncIssue{},
+			Errors:      []syncIssue{},
+		}}
+	}
+	return &syncOutput{tty: term.IsTerminal(os.Stdout.Fd())}
+}
+
+// printf prints human output, on a terminal only
+func (o *syncOutput) printf(format string, args ...any) {
+	if !o.tty {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.erase()
+	fmt.Printf(format, args...)
+	fmt.Print(o.line)
+}
+
+// result reports the outcome for one path off a terminal: a line, or an
+// entry in the JSON report. err is why it was skipped or failed.
+func (o *syncOutput) result(status, path string, err error) {
+	if r := o.report; r != nil {
+		switch status {
+		case "accepted":
+			r.Accepted = append(r.Accepted, path)
+		case "written":
+			r.Written = append(r.Written, path)
+		case "deleted":
+			r.Deleted = append(r.Deleted, path)
+		case "conflict":
+			r.Conflicts = append(r.Conflicts, path)
+		case "unsent":
+			r.Unsent = append(r.Unsent, path)
+		case "attachment":
+			r.Attachments = append(r.Attachments, path)
+		case "skipped":
+			r.Skipped = append(r.Skipped, newSyncIssue(path, err))
+		case "failed":
+			r.Errors = append(r.Errors, newSyncIssue(path, err))
+		}
+		return
+	}
+	if o.tty {
+		return
+	}
+	fields := []string{status, path}
+	if err != nil {
+		fields = append(fields, err.Error())
+	}
+	fmt.Println(strings.Join(fields, "\t"))
+}
+
+// summary reports key=value counts: the last line off a terminal, or the
+// report's summary
+func (o *syncOutput) summary(pairs ...any) {
+	if o.report != nil {
+		for i := 0; i+1 < len(pairs); i += 2 {
+			o.report.Summary[fmt.Sprint(pairs[i])] = pairs[i+1]
+		}
+		return
+	}
+	if o.tty {
+		return
+	}
+	fields := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		fields = append(fields, fmt.Sprintf("%v=%v", pairs[i], pairs[i+1]))
+	}
+	fmt.Println("summary\t" + strings.Join(fields, " "))
+}
+
+// finish prints the JSON report, if that's the output, for command
+// ending with err
+func (o *syncOutput) finish(command, vault string, err error) {
+	r := o.report
+	if r == nil {
+		return
+	}
+	r.Command = command
+	r.Vault = vault
+	r.ExitCode = exitCode(err)
+	r.OK = err == nil
+	if err != nil {
+		issue := newSyncIssue("", err)
+		r.Error = &issue
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(r)
+}
+
+// finishSync ends -push or -pull (command) with err: the JSON report, if
+// asked for, and an exit status telling scripts what happened (see
+// exitCode). Returns only on success.
+func finishSync(out *syncOutput, command string, v *vault, err error) {
+	out.finish(command, v.cfg.Name, err)
+	if err == nil {
+		return
+	}
+
+	name := strings.ToUpper(command[:1]) + command[1:]
+	code := exitCode(err)
+	if code == exitConflicts || code == exitIncomplete {
+		exitf(code, "%s incomplete: %v", name, err)
+	}
+	exitf(code, "%s failed: %v", name, err)
+}
+
+// exitCode is the exit status for a -push or -pull ending with err
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errInterrupted):
+		return exitInterrupted
+	case errors.Is(err, errIncomplete):
+		return exitIncomplete
+	case errors.Is(err, errConflict):
+		return exitConflicts
+	}
+	return exitFailed
+}
+
+func newSyncIssue(path string, err error) syncIssue {
+	issue := syncIssue{Path: path, Code: errorCode(err)}
+	if err != nil {
+		issue.Message = err.Error()
+	}
+	return issue
+}
+
+// errorCode classifies err for scripts: interrupted, incomplete, conflict,
+// secret, vetoed, unsealed, auth, rate_limited, unavailable, too_large,
+// client, server, network, timeout, io or error
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, errInterrupted), errors.Is(err, context.Canceled):
+		return "interrupted"
+	case errors.Is(err, errIncomplete):
+		return "incomplete"
+	case errors.Is(err, errConflict):
+		return "conflict"
+	case errors.Is(err, secrets.ErrFound):
+		return "secret"
+	case errors.Is(err, hooks.ErrVetoed):
+		return "vetoed"
+	case errors.Is(err, note.ErrUnsealed):
+		return "unsealed"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	}
+
+	// 🔵 GO CONCEPT: errors.As
+	// errors.As finds the first error in the chain of a given type, so
+	// wrapped server and network errors can still be told apart.
+	var statusErr *client.StatusError
+	if errors.As(err, &statusErr) {
+		switch {
+		case statusErr.StatusCode == http.StatusUnauthorized, statusErr.StatusCode == http.StatusForbidden:
+			return "auth"
+		case statusErr.StatusCode == http.StatusTooManyRequests:
+			return "rate_limited"
+		case statusErr.StatusCode == http.StatusRequestEntityTooLarge:
+			return "too_large"
+		case statusErr.Temporary():
+			return "unavailable"
+		case statusErr.StatusCode < 500:
+			return "client"
+		}
+		return "server"
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return "io"
+	}
+	return "error"
+}
+
+// progress replaces the progress line. Redraws are limited to ten a
+// second; callbacks fire far more often than that on fast disks.
+func (o *syncOutput) progress(format string, args ...any) {
+	if !o.tty {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	line := fmt.Sprintf(format, args...)
+	if time.Since(o.drawn) < 100*time.Millisecond {
+		o.line = line // Shown by the next redraw
+		return
+	}
+	o.erase()
+	o.line = line
+	o.drawn = time.Now()
+	fmt.Print(o.line)
+}
+
+// done removes the progress line
+func (o *syncOutput) done() {
+	if !o.tty {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.erase()
+	o.line = ""
+	o.drawn = time.Time{}
+}
+
+// erase clears the progress line so the cursor is back at its start
+func (o *syncOutput) erase() {
+	if o.line != "" {
+		// Carriage return, then clear to the end of the line
+		fmt.Print("\r\033[K")
+	}
+}
+
+// eta estimates the time left when done of total took since start, or ""
+// before there is anything to go by
+func eta(start time.Time, done, total int64) string {
+	if done <= 0 || done >= total {
+		return ""
+	}
+	elapsed := time.Since(start)
+	left := time.Duration(float64(elapsed) * float64(total-done) / float64(done))
+	return " · ETA " + left.Round(time.Second).String()
+}
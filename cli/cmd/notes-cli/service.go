@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/daphen/notes-cli/internal/config"
+	"github.com/daphen/notes-cli/internal/daemon"
+	"github.com/daphen/notes-cli/internal/state"
+)
+
+// serviceCmd handles `notes-cli service install|uninstall|status`, which
+// manage a systemd user unit running the daemon at login. With -vault the
+// unit syncs only that vault and gets its own name and socket.
+func serviceCmd(cfg *config.Config, vaultName string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: notes-cli [-vault name] service install|uninstall|status")
+	}
+	sub, args := args[0], args[1:]
+
+	unit := unitName(vaultName)
+	unitPath, err := unitFile(unit)
+	if err != nil {
+		return err
+	}
+
+	switch sub {
+	case "install":
+		fs := flag.NewFlagSet("service install", flag.ExitOnError)
+		var env envFlags
+		fs.Var(&env, "env", "Extra KEY=VALUE for the unit's environment (repeatable)")
+		fs.Parse(args)
+		return installService(cfg, vaultName, unit, unitPath, env)
+
+	case "uninstall":
+		if _, err := os.Stat(unitPath); os.IsNotExist(err) {
+			return fmt.Errorf("%s is not installed", unit)
+		}
+		// Stopping may fail if it never ran; removing the file is what counts
+		systemctl("disable", "--now", unit)
+		if err := os.Remove(unitPath); err != nil {
+			return err
+		}
+		if _, err := systemctl("daemon-reload"); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Removed %s\n", unitPath)
+		return nil
+
+	case "status":
+		return serviceStatus(vaultName, unit, unitPath)
+	}
+
+	return fmt.Errorf("unknown service command %q (install, uninstall, status)", sub)
+}
+
+func installService(cfg *config.Config, vaultName, unit, unitPath string, extra envFlags) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate notes-cli: %w", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+
+	// Fail now rather than in a restart loop
+	names := []string{vaultName}
+	if vaultName == "" && len(cfg.VaultNames()) > 0 {
+		names = cfg.VaultNames()
+	}
+	for _, name := range names {
+		if _, err := cfg.Resolve(name, nil); err != nil {
+			return fmt.Errorf("invalid configuration for vault %s:\n%w", name, err)
+		}
+	}
+	if len(names) > 1 {
+		if err := config.CheckOverrides(nil); err != nil {
+			return err
+		}
+		if err := cfg.CheckVaultDirs(nil); err != nil {
+			return err
+		}
+	}
+
+	content := renderUnit(exe, cfg.Path, vaultName, serviceEnv(extra))
+
+	if err := os.MkdirAll(filepath.Dir(unitPath), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(unitPath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write unit: %w", err)
+	}
+	fmt.Printf("✓ Wrote %s\n", unitPath)
+
+	if _, err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	if _, err := systemctl("enable", "--now", unit); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Enabled and started %s\n", unit)
+	fmt.Printf("  Logs: journalctl --user -u %s -f\n", unit)
+	return nil
+}
+
+// renderUnit writes the systemd unit running the daemon
+func renderUnit(exe, cfgPath, vaultName string, env []string) string {
+	cmd := []string{exe}
+	if cfgPath != "" {
+		if abs, err := filepath.Abs(cfgPath); err == nil {
+			cfgPath = abs
+		}
+		cmd = append(cmd, "-config", cfgPath)
+	}
+	description := "notes-cli sync daemon"
+	if vaultName != "" {
+		cmd = append(cmd, "-vault", vaultName)
+		description += " (vault " + vaultName + ")"
+	}
+	cmd = append(cmd, "daemon")
+
+	quoted := make([]string, len(cmd))
+	for i, arg := range cmd {
+		quoted[i] = systemdQuote(arg)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Written by 'notes-cli service install'\n")
+	fmt.Fprintf(&b, "[Unit]\nDescription=%s\n\n", description)
+	fmt.Fprintf(&b, "[Service]\nType=simple\nExecStart=%s\n", strings.Join(quoted, " "))
+	if vaultName != "" {
+		// Each vault's daemon needs its own socket (%t is the runtime dir)
+		fmt.Fprintf(&b, "Environment=NOTES_DAEMON_SOCKET=%%t/%s\n", socketName(vaultName))
+	}
+	for _, kv := range env {
+		fmt.Fprintf(&b, "Environment=%s\n", systemdQuote(kv))
+	}
+	// SIGTERM lets in-flight pushes finish; give them time before SIGKILL
+	fmt.Fprintf(&b, "Restart=on-failure\nRestartSec=10\nTimeoutStopSec=30\n\n")
+	fmt.Fprintf(&b, "[Install]\nWantedBy=default.target\n")
+	return b.String()
+}
+
+// serviceEnv collects the NOTES_* settings from this shell, which the
+// service would otherwise miss, plus extra, and lists what it copies.
+// Passwords are left out, unit files are world-readable, and so is
+// NOTES_VAULT: the unit's vault is chosen with -vault.
+func serviceEnv(extra envFlags) []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, "NOTES_") || name == "NOTES_DAEMON_SOCKET" || name == "NOTES_SYSTEMCTL" {
+			continue
+		}
+		switch {
+		case config.IsPasswordEnv(name):
+			fmt.Printf("  ⚠ Not copying %s into the unit; use auth_password_command or auth_keyring\n", name)
+		case name == config.VaultEnv:
+			fmt.Printf("  ⚠ Not copying %s into the unit; use -vault to install a unit for one vault\n", name)
+		default:
+			env = append(env, kv)
+		}
+	}
+	sort.Strings(env)
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		fmt.Printf("  Copying %s from this shell into the unit\n", name)
+	}
+	return append(env, extra...)
+}
+
+func serviceStatus(vaultName, unit, unitPath string) error {
+	if _, err := os.Stat(unitPath); os.IsNotExist(err) {
+		fmt.Printf("%s: not installed (run 'notes-cli service install')\n", unit)
+		return nil
+	}
+	fmt.Printf("Unit:    %s\n", unitPath)
+
+	// is-enabled and is-active exit non-zero for "disabled"/"inactive"
+	enabled, _ := systemctl("is-enabled", unit)
+	active, _ := systemctl("is-active", unit)
+	fmt.Printf("Enabled: %s\n", enabled)
+	fmt.Printf("Active:  %s\n", active)
+
+	if active != "active" {
+		return nil
+	}
+
+	path, err := serviceSocket(vaultName)
+	if err != nil {
+		return err
+	}
+	ctl, err := daemon.Dial(path)
+	if err != nil {
+		fmt.Printf("Daemon:  not answering on %s\n", path)
+		return nil
+	}
+	defer ctl.Close()
+
+	st, err := ctl.Status()
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+	printDaemonStatus(st)
+	return nil
+}
+
+// unitName is notes-cli.service, or notes-cli-<vault>.service for a unit
+// syncing one vault
+func unitName(vaultName string) string {
+	if vaultName == "" {
+		return "notes-cli.service"
+	}
+	// Unit names allow only a few characters
+	safe := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '-'
+	}, vaultName)
+	return "notes-cli-" + safe + ".service"
+}
+
+// socketName is the control socket file name of a vault's unit
+func socketName(vaultName string) string {
+	return strings.TrimSuffix(unitName(vaultName), ".service") + ".sock"
+}
+
+// unitFile returns where a user unit lives
+func unitFile(unit string) (string, error) {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".config")
+	}
+	return filepath.Join(base, "systemd", "user", unit), nil
+}
+
+// serviceSocket returns the control socket of the unit's daemon
+func serviceSocket(vaultName string) (string, error) {
+	if vaultName == "" {
+		return daemon.SocketPath()
+	}
+	name := socketName(vaultName)
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, name), nil
+	}
+	dir, err := state.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// systemctl runs `systemctl --user args...` and returns its trimmed
+// output. NOTES_SYSTEMCTL overrides the binary, e.g. for testing.
+func systemctl(args ...string) (string, error) {
+	name := os.Getenv("NOTES_SYSTEMCTL")
+	if name == "" {
+		name = "systemctl"
+	}
+
+	var out bytes.Buffer
+	cmd := exec.Command(name, append([]string{"--user"}, args...)...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+
+	result := strings.TrimSpace(out.String())
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return "", fmt.Errorf("systemctl not available: %w", err)
+	}
+	if err != nil {
+		return result, fmt.Errorf("systemctl --user %s: %s", strings.Join(args, " "), result)
+	}
+	return result, nil
+}
+
+// systemdQuote quotes a word for ExecStart= or Environment= if needed.
+// % starts a specifier in unit files, so a literal one is doubled.
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// envFlags collects repeated -env KEY=VALUE flags
+type envFlags []string
+
+// 🔵 GO CONCEPT: flag.Value
+// Any type with String and Set methods can be a flag; Set is called once
+// per occurrence, so the flag can repeat.
+func (e *envFlags) String() string { return strings.Join(*e, ",") }
+
+func (e *envFlags) Set(kv string) error {
+	if !strings.Contains(kv, "=") {
+		return fmt.Errorf("expected KEY=VALUE, got %q", kv)
+	}
+	*e = append(*e, kv)
+	return nil
+}
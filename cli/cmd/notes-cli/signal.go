@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// errInterrupted is returned by -push and -pull when stopped with Ctrl+C
+var errInterrupted = errors.New("interrupted")
+
+// shutdownGrace is how long syncs in flight may keep going after we're
+// asked to stop, before their requests are cancelled
+const shutdownGrace = 5 * time.Second
+
+// signalContext returns a context cancelled on the first SIGINT or
+// SIGTERM, so sync loops can finish the push in flight and save their
+// state before exiting. A second signal exits immediately. Call release
+// when done.
+func signalContext() (ctx context.Context, release func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	quit := make(chan struct{})
+
+	// 🔵 GO CONCEPT: os/signal
+	// signal.Notify delivers signals on a channel instead of killing the
+	// process, leaving the shutdown to us.
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-sigs:
+			fmt.Fprintf(os.Stderr, "Received %v, finishing in-flight syncs (again to quit now)...\n", sig)
+			cancel()
+		case <-quit:
+			return
+		}
+
+		select {
+		case <-sigs:
+			os.Exit(1)
+		case <-quit:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(sigs)
+		close(quit)
+		cancel()
+	}
+}
+
+// withGrace returns a context that stays alive for grace after ctx is
+// done. Syncs run under it, so one already in flight when ctx is cancelled
+// can finish, while a stuck request is still cut off.
+func withGrace(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	// 🔵 GO CONCEPT: context.WithoutCancel / AfterFunc
+	// WithoutCancel keeps ctx's values but not its cancellation; AfterFunc
+	// runs a function once ctx is done, here to start the grace timer.
+	work, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		time.AfterFunc(grace, cancel)
+	})
+	return work, func() {
+		stop()
+		cancel()
+	}
+}
+
+// waitSyncs waits for the sync goroutines after the TUI has exited,
+// telling the user if that takes a moment
+func waitSyncs(wg *sync.WaitGroup) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-time.After(200 * time.Millisecond):
+		fmt.Fprintln(os.Stderr, "Finishing in-flight syncs...")
+	}
+	<-done
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/daphen/notes-cli/internal/syncer"
+	"github.com/daphen/notes-cli/internal/watcher"
+)
+
+// statusReport is the --json output of the status command
+type statusReport struct {
+	NotesDir         string   `json:"notesDir"`
+	APIURL           string   `json:"apiUrl"`
+	Clean            bool     `json:"clean"`
+	InSync           int      `json:"inSync"`
+	LocalOnly        []string `json:"localOnly"`
+	RemoteOnly       []string `json:"remoteOnly"`
+	ModifiedLocally  []string `json:"modifiedLocally"`
+	ModifiedRemotely []string `json:"modifiedRemotely"`
+	DeletedLocally   []string `json:"deletedLocally"`
+	DeletedRemotely  []string `json:"deletedRemotely"`
+	Conflicted       []string `json:"conflicted"`
+}
+
+// statusCmd compares the local vault with the server and prints what is
+// out of sync, like `git status`
+func statusCmd(v *vault, args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	jsonOut := fs.Bool("json", false, "Print machine-readable JSON")
+	fs.Parse(args)
+
+	ctx, release := signalContext()
+	defer release()
+
+	status, err := compareWithServer(ctx, v)
+	if err != nil {
+		return err
+	}
+
+	report := statusReport{
+		NotesDir:         v.cfg.NotesDir,
+		APIURL:           v.cfg.APIURL,
+		Clean:            status.Clean(),
+		InSync:           len(status.Filter(syncer.InSync)),
+		LocalOnly:        nonNil(status.Filter(syncer.LocalOnly)),
+		RemoteOnly:       nonNil(status.Filter(syncer.RemoteOnly)),
+		ModifiedLocally:  nonNil(status.Filter(syncer.ModifiedLocally)),
+		ModifiedRemotely: nonNil(status.Filter(syncer.ModifiedRemotely)),
+		DeletedLocally:   nonNil(status.Filter(syncer.DeletedLocally)),
+		DeletedRemotely:  nonNil(status.Filter(syncer.DeletedRemotely)),
+		Conflicted:       nonNil(status.Filter(syncer.Conflicted)),
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	fmt.Printf("Notes in %s\n", report.NotesDir)
+	fmt.Printf("Server  %s\n", report.APIURL)
+
+	printSection := func(title, hint, label string, paths []string) {
+		if len(paths) == 0 {
+			return
+		}
+		fmt.Printf("\n%s:\n", title)
+		if hint != "" {
+			fmt.Printf("  (%s)\n", hint)
+		}
+		for _, path := range paths {
+			fmt.Printf("        %-15s %s\n", label, path)
+		}
+	}
+
+	printSection("Conflicts", "changed on both sides since the last sync", "both modified:", report.Conflicted)
+	printSection("Modified locally", "use 'notes-cli -push' to upload", "modified:", report.ModifiedLocally)
+	printSection("Modified remotely", "use 'notes-cli -pull' to download", "modified:", report.ModifiedRemotely)
+	printSection("Deleted locally", "removed here since the last sync", "deleted:", report.DeletedLocally)
+	printSection("Deleted remotely", "removed on the server since the last sync", "deleted:", report.DeletedRemotely)
+	printSection("Local only", "not on the server yet", "new file:", report.LocalOnly)
+	printSection("Remote only", "not on this machine yet", "new file:", report.RemoteOnly)
+
+	if report.Clean {
+		fmt.Printf("\nEverything up to date (%d notes)\n", report.InSync)
+	} else {
+		fmt.Printf("\n%d notes in sync\n", report.InSync)
+	}
+	return nil
+}
+
+// compareWithServer reads the whole vault and the server listing and
+// classifies every note
+func compareWithServer(ctx context.Context, v *vault) (*syncer.Status, error) {
+	changes, err := watcher.ReadNotes(ctx, v.cfg.NotesDir, v.ignore)
+	if err != nil {
+		return nil, err
+	}
+
+	local := make(map[string]string, len(changes))
+	for _, change := range changes {
+		local[change.Path] = change.Content
+	}
+
+	resp, err := v.apiClient.Pull(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return syncer.Compare(local, resp.Changes, v.st), nil
+}
+
+// nonNil makes empty lists encode as [] rather than null
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,504 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/daphen/notes-cli/internal/client"
+	"github.com/daphen/notes-cli/internal/hashing"
+	"github.com/daphen/notes-cli/internal/hooks"
+	"github.com/daphen/notes-cli/internal/journal"
+	"github.com/daphen/notes-cli/internal/metrics"
+	"github.com/daphen/notes-cli/internal/note"
+	"github.com/daphen/notes-cli/internal/secrets"
+	"github.com/daphen/notes-cli/internal/state"
+	"github.com/daphen/notes-cli/internal/syncer"
+	"github.com/daphen/notes-cli/internal/watcher"
+)
+
+// errConflict is returned when a remote change would overwrite local edits.
+// The remote version is kept in the sync state for the conflict view.
+var errConflict = errors.New("changed both locally and on the server")
+
+// applyRemoteNote writes a note received from the server to disk and
+// records it in the sync state. Files that already hold the same content
+// are left alone; the server's checksum may still be a legacy MD5, which
+// hashing.Matches handles. Local edits made since the last sync are never
+// overwritten - a conflict is recorded instead and errConflict returned.
+// Likewise a note deleted locally isn't brought back unless it changed on
+// the server; the watcher's startup scan pushes the delete.
+// Returns whether the file was written.
+func applyRemoteNote(v *vault, n client.Note) (bool, error) {
+	fullPath := filepath.Join(v.cfg.NotesDir, n.Path)
+
+	if n.DeletedAt != "" {
+		return applyRemoteDelete(v, n.Path, fullPath)
+	}
+
+	if existing, err := os.ReadFile(fullPath); err == nil {
+		local := string(existing)
+		if local == n.Content || hashing.Matches(n.Checksum, local) {
+			v.st.Record(n.Path, note.CalculateChecksum(local), n.UpdatedAt)
+			v.st.ResolveConflict(n.Path)
+			return false, nil
+		}
+
+		// The server still has the version we last synced, e.g. the feed
+		// echoing our own push while the user kept typing: nothing new
+		entry, known := v.st.Get(n.Path)
+		if known && hashing.Matches(entry.Checksum, n.Content) {
+			return false, nil
+		}
+		if !known || !hashing.Matches(entry.Checksum, local) {
+			v.st.AddConflict(n.Path, n.Content, n.UpdatedAt)
+			return false, fmt.Errorf("%s: %w", n.Path, errConflict)
+		}
+	} else if entry, known := v.st.Get(n.Path); known && hashing.Matches(entry.Checksum, n.Content) {
+		return false, nil
+	}
+
+	dir := filepath.Dir(fullPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := os.WriteFile(fullPath, []byte(n.Content), 0644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", n.Path, err)
+	}
+
+	// Preserve server's modification time
+	if n.UpdatedAt != "" {
+		if modTime, err := time.Parse(time.RFC3339, n.UpdatedAt); err == nil {
+			os.Chtimes(fullPath, modTime, modTime)
+		}
+	}
+
+	v.st.Record(n.Path, note.CalculateChecksum(n.Content), n.UpdatedAt)
+	metrics.Bytes.Add(float64(len(n.Content)), v.cfg.Name, "pull")
+	return true, nil
+}
+
+// pullNote applies a remote note, records the outcome in the journal and
+// runs the post_pull or on_conflict hook. Notes that were already up to
+// date are not journaled.
+func pullNote(ctx context.Context, v *vault, n client.Note) (bool, error) {
+	written, err := applyRemoteNote(v, n)
+	if written || err != nil {
+		action := "update"
+		if n.DeletedAt != "" {
+			action = "delete"
+		}
+		v.jr.Log(journal.Pull, action, n.Path, journalResult(err), err)
+		logResult(v, "pull", action, n.Path, err)
+
+		switch {
+		case written:
+			v.hooks.Run(ctx, hooks.PostPull, v.hookNote(n.Path, n.Title, action))
+		case errors.Is(err, errConflict):
+			v.hooks.Run(ctx, hooks.OnConflict, v.hookNote(n.Path, n.Title, "conflict"))
+		}
+	}
+	return written, err
+}
+
+// logResult logs the outcome of syncing one path: failures as warnings,
+// conflicts and skips at info level, successes at debug level
+func logResult(v *vault, direction, action, path string, err error) {
+	result := journalResult(err)
+	metrics.Syncs.Inc(v.cfg.Name, direction, string(result))
+	if result == journal.Failed {
+		metrics.Failures.Inc(v.cfg.Name, direction, failureType(err))
+	}
+
+	attrs := []any{"direction", direction, "action", action, "path", path}
+	switch result {
+	case journal.OK:
+		metrics.MarkSuccess(v.cfg.Name)
+		v.log.Debug("synced", attrs...)
+	case journal.Conflict:
+		v.log.Info("conflict", attrs...)
+	case journal.Skipped:
+		v.log.Info("skipped", append(attrs, "reason", err)...)
+	default:
+		v.log.Warn("sync failed", append(attrs, "err", err)...)
+	}
+}
+
+// journalResult classifies a sync error for the journal
+func journalResult(err error) journal.Result {
+	switch {
+	case err == nil:
+		return journal.OK
+	case errors.Is(err, errConflict):
+		return journal.Conflict
+	case errors.Is(err, note.ErrUnsealed), errors.Is(err, client.ErrNoAttachments), errors.Is(err, hooks.ErrVetoed),
+		errors.Is(err, secrets.ErrFound):
+		return journal.Skipped
+	}
+	return journal.Failed
+}
+
+// applyRemoteDelete removes a note deleted on the server, but only if the
+// local copy is still the version we last synced - local edits are kept.
+func applyRemoteDelete(v *vault, path, fullPath string) (bool, error) {
+	existing, err := os.ReadFile(fullPath)
+	if os.IsNotExist(err) {
+		v.st.Remove(path)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	entry, known := v.st.Get(path)
+	if !known || !hashing.Matches(entry.Checksum, string(existing)) {
+		return false, nil
+	}
+
+	if err := os.Remove(fullPath); err != nil {
+		return false, fmt.Errorf("failed to delete %s: %w", path, err)
+	}
+	v.st.Remove(path)
+	return true, nil
+}
+
+// followRemoteChanges applies changes from the server's change feed as they
+// arrive, until ctx is done. report is called after each batch with the
+// paths written to disk.
+func followRemoteChanges(ctx context.Context, v *vault, report func(written []string, err error)) {
+	for event := range v.apiClient.Feed(ctx, v.st.GetCursor()) {
+		if event.Err != nil {
+			v.log.Warn("change feed", "err", event.Err)
+			report(nil, event.Err)
+			continue
+		}
+
+		var written []string
+		for _, n := range event.Changes {
+			ok, err := pullNote(ctx, v, n)
+			if err != nil {
+				report(written, err)
+				continue
+			}
+			if ok {
+				written = append(written, n.Path)
+			}
+		}
+
+		v.st.SetCursor(event.Cursor)
+		if err := v.st.Save(); err != nil {
+			v.log.Error("failed to save sync state", "err", err)
+			report(written, fmt.Errorf("failed to save sync state: %w", err))
+			continue
+		}
+		metrics.MarkSuccess(v.cfg.Name)
+		report(written, nil)
+	}
+}
+
+// pushChange sends one change from the watcher to the server, records it
+// in the sync state and journals the outcome
+func pushChange(ctx context.Context, v *vault, change watcher.FileChange) error {
+	if change.Attachment {
+		_, err := pushAttachmentChanges(ctx, v, []watcher.FileChange{change})
+		return err
+	}
+
+	sent, err := sendChange(ctx, v, change)
+	if sent || err != nil {
+		v.jr.Log(journal.Push, change.Action, change.Path, journalResult(err), err)
+		logResult(v, "push", change.Action, change.Path, err)
+	}
+	return err
+}
+
+// pushAttachmentChanges sends attachment changes from the watcher as one
+// batch: removed paths are deleted on the server and the rest uploaded
+// with their refs in a single request. Attachments whose content matches
+// the sync state, e.g. just written by a pull, aren't sent again. Returns
+// the paths sent.
+func pushAttachmentChanges(ctx context.Context, v *vault, changes []watcher.FileChange) ([]string, error) {
+	// Only the latest change to each path matters
+	latest := make(map[string]string)
+	for _, change := range changes {
+		latest[filepath.ToSlash(change.Path)] = change.Action
+	}
+
+	var updated, removed []string
+	for path, action := range latest {
+		if action != "delete" {
+			updated = append(updated, path)
+		} else if _, known := v.st.AttachmentHash(path); known {
+			removed = append(removed, path)
+		}
+	}
+	slices.Sort(updated)
+	slices.Sort(removed)
+
+	var sent []string
+	logAll := func(action string, paths []string, err error) {
+		for _, path := range paths {
+			v.jr.Log(journal.Push, action, path, journalResult(err), err)
+			logResult(v, "push", action, path, err)
+		}
+	}
+
+	if len(removed) > 0 {
+		err := v.apiClient.DeleteAttachmentRefs(ctx, removed)
+		logAll("delete", removed, err)
+		if err != nil {
+			return sent, err
+		}
+		for _, path := range removed {
+			v.st.RemoveAttachment(path)
+		}
+		sent = append(sent, removed...)
+	}
+
+	if len(updated) > 0 {
+		report, err := syncer.PushAttachmentFiles(ctx, v.apiClient, v.cfg.NotesDir, updated, v.st.AttachmentHash)
+		if err != nil {
+			logAll("attachment", updated, err)
+			return sent, err
+		}
+		var pushed []string
+		for _, ref := range report.Synced {
+			pushed = append(pushed, ref.Path)
+		}
+		logAll("attachment", pushed, nil)
+		sent = append(sent, pushed...)
+		if err := recordAttachments(v, report.Synced); err != nil {
+			return sent, err
+		}
+	}
+
+	if len(removed) > 0 {
+		if err := v.st.Save(); err != nil {
+			return sent, fmt.Errorf("failed to save sync state: %w", err)
+		}
+	}
+	return sent, nil
+}
+
+// recordAttachments remembers the attachments whose content now matches
+// the server, so the watcher doesn't send them back
+func recordAttachments(v *vault, refs []client.AttachmentRef) error {
+	if len(refs) == 0 {
+		return nil
+	}
+	for _, ref := range refs {
+		v.st.RecordAttachment(ref.Path, ref.Hash)
+	}
+	if err := v.st.Save(); err != nil {
+		return fmt.Errorf("failed to save sync state: %w", err)
+	}
+	return nil
+}
+
+// sendChange does the work of pushChange for notes, reporting whether
+// anything was sent
+func sendChange(ctx context.Context, v *vault, change watcher.FileChange) (bool, error) {
+	// Process the note with business logic
+	n, err := buildNote(change.Path, change.Content, change.Action)
+	if err != nil {
+		return false, err
+	}
+
+	// Nothing to do if this is exactly what we last synced, e.g. the
+	// watcher seeing a file we just wrote from the change feed
+	entry, known := v.st.Get(n.Path)
+	if known && n.Action != "delete" && entry.Checksum == n.Checksum {
+		return false, nil
+	}
+	if err := checkConflict(v, n.Path); err != nil {
+		return false, err
+	}
+
+	if n, err = prePush(ctx, v, n); err != nil {
+		return false, err
+	}
+	if n, err = checkSecrets(v, n); err != nil {
+		return false, err
+	}
+
+	resp, err := v.apiClient.Push(ctx, []client.Note{n})
+	if err != nil {
+		return true, err
+	}
+	if !slices.Contains(resp.Accepted, n.Path) {
+		return true, fmt.Errorf("server did not accept %s", n.Path)
+	}
+
+	if n.Action == "delete" {
+		v.st.Remove(n.Path)
+	} else {
+		v.st.Record(n.Path, n.Checksum, "")
+		metrics.Bytes.Add(float64(len(n.Content)), v.cfg.Name, "push")
+	}
+	if err := v.st.Save(); err != nil {
+		return true, err
+	}
+
+	if !known && n.Action != "delete" {
+		v.hooks.Run(ctx, hooks.OnCreate, v.hookNote(n.Path, n.Title, "create"))
+	}
+	return true, nil
+}
+
+// checkConflict refuses to push a note with an unresolved conflict. The
+// push would overwrite the server's side, and the feed echoing it back
+// would then drop the conflict; the conflict view decides which side wins.
+func checkConflict(v *vault, path string) error {
+	if _, conflicted := v.st.GetConflict(path); conflicted {
+		return fmt.Errorf("%s: %w, resolve it before pushing", path, errConflict)
+	}
+	return nil
+}
+
+// prePush runs the pre_push hook for n, which may veto it. Hooks such as
+// formatters may also rewrite the file, so it's read again and the note
+// rebuilt from what the hook left.
+func prePush(ctx context.Context, v *vault, n client.Note) (client.Note, error) {
+	if !v.hooks.Has(hooks.PrePush) {
+		return n, nil
+	}
+	if err := v.hooks.Run(ctx, hooks.PrePush, v.hookNote(n.Path, n.Title, n.Action)); err != nil {
+		return n, err
+	}
+	if n.Action == "delete" {
+		return n, nil
+	}
+
+	content, err := os.ReadFile(filepath.Join(v.cfg.NotesDir, n.Path))
+	if err != nil {
+		return n, err
+	}
+	if note.CalculateChecksum(string(content)) == n.Checksum {
+		return n, nil
+	}
+	return buildNote(n.Path, string(content), n.Action)
+}
+
+// checkSecrets scans a note about to be pushed for credentials and
+// applies the vault's secret_scan setting. Redacting rewrites the file as
+// well, so it keeps matching what the server has. Sealed notes are only
+// ciphertext and aren't scanned.
+func checkSecrets(v *vault, n client.Note) (client.Note, error) {
+	if v.cfg.SecretScan == "off" || n.Action == "delete" || note.IsSealed(n.Content) {
+		return n, nil
+	}
+	found := secrets.Scan(n.Content)
+	if len(found) == 0 {
+		return n, nil
+	}
+
+	switch v.cfg.SecretScan {
+	case "warn":
+		for _, f := range found {
+			v.log.Warn("pushing possible secret", "path", n.Path, "secret", f.Rule, "line", f.Line)
+		}
+		return n, nil
+
+	case "redact":
+		orig, err := keepOriginal(v, n.Path, n.Content)
+		if err != nil {
+			return n, fmt.Errorf("failed to keep a copy of %s before redacting: %w", n.Path, err)
+		}
+		content := secrets.Redact(n.Content, found)
+		if err := replaceFile(filepath.Join(v.cfg.NotesDir, n.Path), content); err != nil {
+			return n, fmt.Errorf("failed to redact %s: %w", n.Path, err)
+		}
+		v.log.Info("redacted possible secrets", "path", n.Path, "secrets", len(found), "original", orig)
+		return buildNote(n.Path, content, n.Action)
+	}
+
+	msg := found[0].String()
+	if len(found) > 1 {
+		msg += fmt.Sprintf(" and %d more", len(found)-1)
+	}
+	return n, fmt.Errorf("%w: %s (mark false positives with %s)", secrets.ErrFound, msg, secrets.AllowMarker)
+}
+
+// keepOriginal saves a note about to be redacted as
+// redacted-<hash>/<path>.<time>.orig in the state directory, outside the
+// notes so the secret is never synced, and returns where
+func keepOriginal(v *vault, path, content string) (string, error) {
+	dir, err := state.VaultFile(v.cfg.NotesDir, "redacted", "")
+	if err != nil {
+		return "", err
+	}
+
+	dst := filepath.Join(dir, filepath.FromSlash(path)+"."+time.Now().Format("20060102-150405")+".orig")
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return "", err
+	}
+	return dst, os.WriteFile(dst, []byte(content), 0600)
+}
+
+// replaceFile swaps in new content for an existing note in one rename,
+// keeping its permissions. The watcher never sees the note truncated or
+// half-written, and neither does an editor reloading it.
+func replaceFile(path, content string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".notes-cli-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// buildNote runs the note business logic and converts the result into the
+// API representation. Encrypted notes that haven't been sealed yet are
+// refused so their plaintext never leaves the machine.
+func buildNote(path, content, action string) (client.Note, error) {
+	if action != "delete" {
+		if err := note.CheckSyncable(content); err != nil {
+			return client.Note{}, err
+		}
+	}
+
+	processed := note.ProcessNote(path, content, action)
+	return client.Note{
+		Path:     processed.Path,
+		Title:    processed.Title,
+		Content:  processed.Content,
+		Checksum: processed.Checksum,
+		Action:   processed.Action,
+	}, nil
+}
+
+// formatBytes renders a byte count in human units
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/daphen/notes-cli/internal/client"
+	"github.com/daphen/notes-cli/internal/config"
+	"github.com/daphen/notes-cli/internal/device"
+	"github.com/daphen/notes-cli/internal/hooks"
+	"github.com/daphen/notes-cli/internal/ignore"
+	"github.com/daphen/notes-cli/internal/journal"
+	"github.com/daphen/notes-cli/internal/state"
+	"github.com/daphen/notes-cli/internal/watcher"
+)
+
+// vault bundles everything needed to sync one notes directory with its
+// server. Each configured vault gets its own.
+type vault struct {
+	cfg       *config.Config
+	apiClient *client.Client
+	st        *state.State
+	jr        *journal.Journal
+	ignore    *ignore.Matcher
+	hooks     *hooks.Runner
+	log       *slog.Logger // logger with the vault's name attached
+
+	// Prefix for console output when several vaults share a terminal
+	tag string
+}
+
+// openVault loads the sync state and journal for a vault's directory. The
+// API client is created by connect.
+func openVault(cfg *config.Config) (*vault, error) {
+	matcher, err := ignore.New(cfg.Ignore)
+	if err != nil {
+		return nil, fmt.Errorf("vault %s: %w", cfg.Name, err)
+	}
+
+	// Load sync state
+	st, err := state.Open(cfg.NotesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sync state: %w", err)
+	}
+
+	journalPath, err := journal.DefaultPath(cfg.NotesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate sync journal: %w", err)
+	}
+
+	log := logger.With("vault", cfg.Name)
+	return &vault{
+		cfg:    cfg,
+		st:     st,
+		jr:     journal.Open(journalPath),
+		ignore: matcher,
+		hooks: hooks.New(map[string]string{
+			hooks.PrePush:    cfg.Hooks.PrePush,
+			hooks.PostPull:   cfg.Hooks.PostPull,
+			hooks.OnConflict: cfg.Hooks.OnConflict,
+			hooks.OnCreate:   cfg.Hooks.OnCreate,
+		}, cfg.NotesDir, log),
+		log: log,
+	}, nil
+}
+
+// connectVault opens the named vault, with the environment and command
+// line layered on top, and authenticates with its server
+func connectVault(cfg *config.Config, name string) (*vault, error) {
+	vaultCfg, err := cfg.Resolve(name, flag.CommandLine)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := openVault(vaultCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := v.connect(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// connect resolves the vault's password and logs in to its server
+func (v *vault) connect() error {
+	password, err := v.cfg.Password()
+	if err != nil {
+		return err
+	}
+
+	v.apiClient = client.New(v.cfg.APIURL, password)
+	v.apiClient.SetIdentity(v.cfg.ClientID, device.Current(version))
+	v.apiClient.SetLogger(v.log)
+	if err := v.apiClient.Authenticate(context.Background()); err != nil {
+		v.log.Error("authentication failed", "api_url", v.cfg.APIURL, "err", err)
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	v.log.Info("connected", "api_url", v.cfg.APIURL, "notes_dir", v.cfg.NotesDir)
+	return nil
+}
+
+// watching describes how changes to w's directory are noticed, for the
+// "Watching ..." line
+func watching(w *watcher.Watcher) string {
+	if w.Mode() == watcher.ModePoll {
+		return fmt.Sprintf(" (polling every %s)", w.Interval())
+	}
+	return ""
+}
+
+// hookNote describes a note of this vault to a hook
+func (v *vault) hookNote(path, title, action string) hooks.Note {
+	return hooks.Note{
+		Vault:  v.cfg.Name,
+		Path:   path,
+		File:   filepath.Join(v.cfg.NotesDir, path),
+		Title:  title,
+		Action: action,
+	}
+}
+
+// printf prints console output, prefixed with the vault name if tagged
+func (v *vault) printf(format string, args ...any) {
+	if v.tag != "" {
+		format = "[" + v.tag + "] " + format
+	}
+	fmt.Printf(format, args...)
+}
+
+// newWatcher watches the vault directory, skipping ignored paths
+func (v *vault) newWatcher() (*watcher.Watcher, error) {
+	w, err := watcher.New(v.cfg.NotesDir, watcher.Options{
+		Mode:     watcher.Mode(v.cfg.WatchMode),
+		Interval: v.cfg.PollInterval,
+	})
+	if err != nil {
+		return nil, err
+	}
+	w.SetIgnore(v.ignore)
+	w.SetLogger(v.log)
+	w.SetBaseline(v.synced)
+	return w, nil
+}
+
+// synced returns the checksum of every note as last synced, the baseline
+// for the watcher's startup scan. Unresolved conflicts map to "" so the
+// scan leaves them alone: they are settled in the TUI, not by pushing
+// whatever is on disk.
+func (v *vault) synced() map[string]string {
+	checksums := make(map[string]string)
+	for _, path := range v.st.Paths() {
+		if entry, ok := v.st.Get(path); ok {
+			checksums[path] = entry.Checksum
+		}
+	}
+	for _, path := range v.st.ConflictPaths() {
+		checksums[path] = ""
+	}
+	return checksums
+}
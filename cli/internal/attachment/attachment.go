@@ -0,0 +1,181 @@
+package attachment
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/daphen/notes-cli/internal/hashing"
+)
+
+// Dir is the folder (relative to the notes directory) whose files are
+// always synced, whether or not a note links to them
+const Dir = "attachments"
+
+// extensions are file types treated as attachments anywhere in the vault
+var extensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true,
+	".svg": true, ".bmp": true, ".pdf": true, ".mp3": true, ".mp4": true,
+}
+
+// linkPattern matches markdown images and links: ![alt](target) / [text](target)
+// The target may be wrapped in <> (allowing spaces) and followed by a "title".
+var linkPattern = regexp.MustCompile(`!?\[[^\]]*\]\(\s*(?:<([^>]+)>|([^)\s]+))(?:\s+"[^"]*")?\s*\)`)
+
+// Attachment is one unique file content, possibly present at several paths
+type Attachment struct {
+	Hash  string   // Hex SHA-256 of the content
+	Size  int64    // Size in bytes
+	Paths []string // Paths relative to the notes directory sharing this content
+	Notes []string // Notes referencing any of the paths
+}
+
+// IsAttachment reports whether a path relative to the notes directory
+// should be synced as an attachment
+func IsAttachment(relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	if strings.HasPrefix(relPath, Dir+"/") {
+		return !strings.HasSuffix(relPath, ".md")
+	}
+	return extensions[strings.ToLower(path.Ext(relPath))]
+}
+
+// Refs returns the local, non-note files a note links to, as paths relative
+// to the notes directory. External URLs, anchors and links that escape the
+// notes directory are ignored.
+func Refs(notePath, content string) []string {
+	var refs []string
+	seen := make(map[string]bool)
+
+	noteDir := path.Dir(filepath.ToSlash(notePath))
+
+	for _, match := range linkPattern.FindAllStringSubmatch(content, -1) {
+		target := match[1]
+		if target == "" {
+			target = match[2]
+		}
+
+		if strings.Contains(target, "://") || strings.HasPrefix(target, "#") ||
+			strings.HasPrefix(target, "mailto:") {
+			continue
+		}
+
+		// Drop anchors and decode %20 and friends
+		target, _, _ = strings.Cut(target, "#")
+		if decoded, err := url.PathUnescape(target); err == nil {
+			target = decoded
+		}
+		if target == "" || strings.HasSuffix(target, ".md") {
+			continue
+		}
+
+		var rel string
+		if strings.HasPrefix(target, "/") {
+			rel = path.Clean(strings.TrimPrefix(target, "/"))
+		} else {
+			rel = path.Clean(path.Join(noteDir, target))
+		}
+		if rel == "." || strings.HasPrefix(rel, "../") || rel == ".." {
+			continue
+		}
+
+		if !seen[rel] {
+			seen[rel] = true
+			refs = append(refs, rel)
+		}
+	}
+
+	return refs
+}
+
+// HashFile returns the hex SHA-256 and size of a file
+func HashFile(fullPath string) (string, int64, error) {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	hash, size, err := hashing.SumReader(hashing.SHA256, f)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to hash %s: %w", fullPath, err)
+	}
+
+	return hash, size, nil
+}
+
+// Scan finds every attachment in the vault: files linked from the given
+// notes (path -> content) plus everything under Dir. Files with identical
+// content are merged into a single Attachment. Links to missing files and
+// directories are skipped.
+func Scan(notesDir string, notes map[string]string) ([]Attachment, error) {
+	// path -> referencing notes
+	referenced := make(map[string][]string)
+
+	for notePath, content := range notes {
+		for _, ref := range Refs(notePath, content) {
+			referenced[ref] = append(referenced[ref], notePath)
+		}
+	}
+
+	attachDir := filepath.Join(notesDir, Dir)
+	if _, err := os.Stat(attachDir); err == nil {
+		err := filepath.Walk(attachDir, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			rel, _ := filepath.Rel(notesDir, p)
+			rel = filepath.ToSlash(rel)
+			if IsAttachment(rel) {
+				if _, ok := referenced[rel]; !ok {
+					referenced[rel] = nil
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	byHash := make(map[string]*Attachment)
+	for rel, noteRefs := range referenced {
+		fullPath := filepath.Join(notesDir, filepath.FromSlash(rel))
+		if info, err := os.Stat(fullPath); err != nil || !info.Mode().IsRegular() {
+			continue // Dangling link or not a file
+		}
+
+		hash, size, err := HashFile(fullPath)
+		if err != nil {
+			return nil, err
+		}
+
+		att, ok := byHash[hash]
+		if !ok {
+			att = &Attachment{Hash: hash, Size: size}
+			byHash[hash] = att
+		}
+		att.Paths = append(att.Paths, rel)
+		att.Notes = append(att.Notes, noteRefs...)
+	}
+
+	result := make([]Attachment, 0, len(byHash))
+	for _, att := range byHash {
+		sort.Strings(att.Paths)
+		sort.Strings(att.Notes)
+		att.Notes = slices.Compact(att.Notes)
+		result = append(result, *att)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Paths[0] < result[j].Paths[0] })
+
+	return result, nil
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// ErrNoAttachments means the server has no attachment endpoints
+var ErrNoAttachments = errors.New("server does not support attachments")
+
+// AttachmentRef links a path in the vault to attachment content by hash
+type AttachmentRef struct {
+	Path string `json:"path"`
+	Hash string `json:"hash"`
+	Size int64  `json:"size"`
+}
+
+// MissingAttachments asks the server which of the given content hashes it
+// doesn't have yet, so only new content is uploaded
+func (c *Client) MissingAttachments(ctx context.Context, hashes []string) ([]string, error) {
+	jsonData, err := json.Marshal(map[string][]string{"hashes": hashes})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal attachment check: %w", err)
+	}
+
+	var result struct {
+		Missing []string `json:"missing"`
+	}
+	if err := c.doJSON(ctx, "POST", "/api/attachments/check", "attachment check", bytes.NewReader(jsonData), &result); err != nil {
+		return nil, attachmentsUnsupported(err)
+	}
+
+	return result.Missing, nil
+}
+
+// UploadAttachment stores attachment content under its hash
+func (c *Client) UploadAttachment(ctx context.Context, hash string, body io.Reader, size int64, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, "PUT", c.baseURL+"/api/attachments/"+url.PathEscape(hash), body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	c.setHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("attachment upload failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return newStatusError("attachment upload", resp)
+	}
+
+	return nil
+}
+
+// DownloadAttachment writes the content stored under hash to w
+func (c *Client) DownloadAttachment(ctx context.Context, hash string, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/attachments/"+url.PathEscape(hash), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	c.setHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("attachment download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newStatusError("attachment download", resp)
+	}
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to read attachment %s: %w", hash, err)
+	}
+
+	return nil
+}
+
+// PutAttachmentRefs records which vault paths point at which content
+func (c *Client) PutAttachmentRefs(ctx context.Context, refs []AttachmentRef) error {
+	jsonData, err := json.Marshal(map[string][]AttachmentRef{"refs": refs})
+	if err != nil {
+		return fmt.Errorf("failed to marshal attachment refs: %w", err)
+	}
+
+	return c.doJSON(ctx, "POST", "/api/attachments", "attachment refs", bytes.NewReader(jsonData), nil)
+}
+
+// DeleteAttachmentRefs forgets vault paths whose attachment was removed
+// locally. The server keeps content still referenced by other paths.
+func (c *Client) DeleteAttachmentRefs(ctx context.Context, paths []string) error {
+	jsonData, err := json.Marshal(map[string][]string{"paths": paths})
+	if err != nil {
+		return fmt.Errorf("failed to marshal attachment paths: %w", err)
+	}
+
+	if err := c.doJSON(ctx, "DELETE", "/api/attachments", "attachment delete", bytes.NewReader(jsonData), nil); err != nil {
+		return attachmentsUnsupported(err)
+	}
+	return nil
+}
+
+// ListAttachments returns every attachment path known to the server
+func (c *Client) ListAttachments(ctx context.Context) ([]AttachmentRef, error) {
+	var result struct {
+		Refs []AttachmentRef `json:"refs"`
+	}
+	if err := c.doJSON(ctx, "GET", "/api/attachments", "attachment list", nil, &result); err != nil {
+		return nil, attachmentsUnsupported(err)
+	}
+
+	return result.Refs, nil
+}
+
+// attachmentsUnsupported turns a 404 from an attachment endpoint into
+// ErrNoAttachments
+func attachmentsUnsupported(err error) error {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		return ErrNoAttachments
+	}
+	return err
+}
+
+// doJSON sends an authenticated request and decodes a JSON response into
+// out (if not nil)
+func (c *Client) doJSON(ctx context.Context, method, path, op string, body io.Reader, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.setHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newStatusError(op, resp)
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -7,11 +7,20 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/daphen/notes-cli/internal/device"
+	"github.com/daphen/notes-cli/internal/metrics"
 )
 
 // Client handles API communication with the notes server
@@ -20,25 +29,117 @@ type Client struct {
 	password   string
 	httpClient *http.Client
 	authToken  string
+
+	// Who we are, sent with every request
+	clientID string
+	device   device.Info
+
+	// Server capabilities, fetched lazily on first push
+	capsMu sync.Mutex
+	caps   *Capabilities
+
+	// Logs and times every request
+	transport *transport
 }
 
 // 🔵 GO CONCEPT: Constructor pattern
 // Go doesn't have constructors. By convention, we create New* functions.
 // This returns a pointer to a Client with the http client configured.
 func New(baseURL, password string) *Client {
+	tr := &transport{next: http.DefaultTransport, log: slog.New(slog.DiscardHandler)}
 	return &Client{
 		baseURL:  baseURL,
 		password: password,
+		clientID: "notes-cli",
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: tr,
 			// 🔵 GO CONCEPT: Duration literals
 			// Go has built-in duration types. 30 * time.Second = 30 seconds.
 		},
+		transport: tr,
+	}
+}
+
+// SetIdentity sets the client ID and device details sent with every
+// request, so the server can tell this machine's changes apart
+func (c *Client) SetIdentity(clientID string, dev device.Info) {
+	c.clientID = clientID
+	c.device = dev
+}
+
+// SetLogger logs every request to l: failures as warnings, the rest at
+// debug level
+func (c *Client) SetLogger(l *slog.Logger) {
+	c.transport.log = l
+}
+
+// transport logs requests as they complete and records their latency in
+// metrics.RequestDuration. Headers are left out, they carry the auth
+// cookie.
+type transport struct {
+	next http.RoundTripper
+	log  *slog.Logger
+}
+
+// 🔵 GO CONCEPT: http.RoundTripper
+// Every request made by an http.Client goes through its Transport, so
+// wrapping it sees them all - including the change feed's, which reuses
+// this Transport.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(start)
+	attrs := []any{"method", req.Method, "path", req.URL.Path, "duration", elapsed.Round(time.Millisecond)}
+
+	code := "0"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	metrics.RequestDuration.Observe(elapsed.Seconds(), req.Method, route(req.URL.Path), code)
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		// Shutting down, not a failure worth a warning
+		t.log.Debug("request cancelled", attrs...)
+	case err != nil:
+		t.log.Warn("request failed", append(attrs, "err", err)...)
+	case resp.StatusCode >= 400 && resp.StatusCode != http.StatusNotFound:
+		// 404 means an optional endpoint the server doesn't have
+		t.log.Warn("request failed", append(attrs, "status", resp.StatusCode)...)
+	default:
+		t.log.Debug("request", append(attrs, "status", resp.StatusCode)...)
+	}
+	return resp, err
+}
+
+// route trims a path to its first two segments, e.g. /api/attachments
+// for /api/attachments/<hash>, so metrics get one series per endpoint
+func route(path string) string {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 3)
+	return "/" + strings.Join(parts[:min(len(parts), 2)], "/")
+}
+
+// setHeaders adds the auth cookie and identity headers to a request
+func (c *Client) setHeaders(req *http.Request) {
+	if c.authToken != "" {
+		req.Header.Set("Cookie", "notes-auth="+c.authToken)
+	}
+
+	req.Header.Set("X-Client-Id", c.clientID)
+	if c.device.Version != "" {
+		req.Header.Set("User-Agent", fmt.Sprintf("notes-cli/%s (%s)", c.device.Version, c.device.OS))
+	}
+	if c.device.Hostname != "" {
+		req.Header.Set("X-Device-Hostname", c.device.Hostname)
+	}
+	if c.device.OS != "" {
+		req.Header.Set("X-Device-OS", c.device.OS)
 	}
 }
 
 // Authenticate gets an auth token from the server
-func (c *Client) Authenticate() error {
+func (c *Client) Authenticate(ctx context.Context) error {
 	// 🔵 GO CONCEPT: Methods (receivers)
 	// (c *Client) is a method receiver - like 'this' or 'self' in other languages.
 	// The * means we receive a pointer, so we can modify the Client.
@@ -58,11 +159,14 @@ func (c *Client) Authenticate() error {
 		// This is like Error.cause in JavaScript.
 	}
 
-	resp, err := c.httpClient.Post(
-		c.baseURL+"/api/auth",
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/auth", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create auth request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.setHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("auth request failed: %w", err)
 	}
@@ -73,8 +177,7 @@ func (c *Client) Authenticate() error {
 	// Similar to try/finally but more concise.
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("auth failed: %s - %s", resp.Status, string(body))
+		return newStatusError("auth", resp)
 	}
 
 	// Extract cookie
@@ -88,20 +191,73 @@ func (c *Client) Authenticate() error {
 	return fmt.Errorf("no auth token received")
 }
 
+// StatusError is returned when the server answers with a non-200 status
+type StatusError struct {
+	Op         string // "sync", "pull", ...
+	StatusCode int
+	Status     string
+	Body       string
+	RetryAfter time.Duration // From the Retry-After header, 0 if absent
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s failed: %s - %s", e.Op, e.Status, e.Body)
+}
+
+// Temporary reports whether retrying later might succeed
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode == http.StatusServiceUnavailable
+}
+
+// newStatusError reads the response body into a StatusError
+func newStatusError(op string, resp *http.Response) *StatusError {
+	body, _ := io.ReadAll(resp.Body)
+
+	return &StatusError{
+		Op:         op,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// parseRetryAfter reads a Retry-After header in either of its forms, a
+// number of seconds or an HTTP date. Returns 0 if it's missing, invalid or
+// already past.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs > 0 {
+			return time.Duration(secs) * time.Second
+		}
+		return 0
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
 // Note represents a note in the system
 type Note struct {
 	Path      string `json:"path"`
 	Title     string `json:"title"`
 	Content   string `json:"content"`
 	Checksum  string `json:"checksum"`
-	Action    string `json:"action"`    // "create", "update", "delete"
-	UpdatedAt string `json:"updatedAt"` // ISO timestamp from server
+	Action    string `json:"action"`              // "create", "update", "delete"
+	UpdatedAt string `json:"updatedAt"`           // ISO timestamp from server
+	DeletedAt string `json:"deletedAt,omitempty"` // Set by the server for deleted notes
 }
 
 // SyncRequest is the payload we send to /api/sync
 type SyncRequest struct {
-	ClientID string `json:"clientId"`
-	Changes  []Note `json:"changes"`
+	ClientID string       `json:"clientId"`
+	Device   *device.Info `json:"device,omitempty"`
+	Changes  []Note       `json:"changes"`
 }
 
 // SyncResponse is what we get back from /api/sync
@@ -109,27 +265,40 @@ type SyncResponse struct {
 	Accepted  []string `json:"accepted"`
 	Conflicts []string `json:"conflicts"`
 	Changes   []Note   `json:"changes"`
+	Timestamp string   `json:"timestamp"` // Server time of the response, usable as since=
 }
 
-// Push sends local changes to the server
-func (c *Client) Push(notes []Note) (*SyncResponse, error) {
+// Push sends local changes to the server in a single request.
+// The body is encoded while it is sent, and gzip-compressed when the
+// server advertises support for it.
+func (c *Client) Push(ctx context.Context, notes []Note) (*SyncResponse, error) {
 	reqBody := SyncRequest{
-		ClientID: "notes-cli",
+		ClientID: c.clientID,
 		Changes:  notes,
 	}
+	if c.device != (device.Info{}) {
+		reqBody.Device = &c.device
+	}
 
-	jsonData, err := json.Marshal(reqBody)
+	caps, err := c.Capabilities(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal sync request: %w", err)
+		return nil, err
 	}
+	compress := caps.Supports("gzip")
 
-	req, err := http.NewRequest("POST", c.baseURL+"/api/sync", bytes.NewBuffer(jsonData))
+	body := encodeSyncRequest(reqBody, compress)
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/sync", body)
 	if err != nil {
+		// Nobody will read the body - stop the encoding goroutine
+		body.CloseWithError(err)
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Cookie", "notes-auth="+c.authToken)
+	c.setHeaders(req)
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -138,8 +307,7 @@ func (c *Client) Push(notes []Note) (*SyncResponse, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("sync failed: %s - %s", resp.Status, string(body))
+		return nil, newStatusError("sync", resp)
 	}
 
 	var syncResp SyncResponse
@@ -151,13 +319,19 @@ func (c *Client) Push(notes []Note) (*SyncResponse, error) {
 }
 
 // Pull fetches changes from the server
-func (c *Client) Pull() (*SyncResponse, error) {
-	req, err := http.NewRequest("GET", c.baseURL+"/api/sync", nil)
+func (c *Client) Pull(ctx context.Context) (*SyncResponse, error) {
+	return c.PullProgress(ctx, nil)
+}
+
+// PullProgress is Pull, calling progress, if set, with the response bytes
+// received so far as they arrive
+func (c *Client) PullProgress(ctx context.Context, progress func(received int64)) (*SyncResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/sync", nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Cookie", "notes-auth="+c.authToken)
+	c.setHeaders(req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -166,14 +340,32 @@ func (c *Client) Pull() (*SyncResponse, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("pull failed: %s - %s", resp.Status, string(body))
+		return nil, newStatusError("pull", resp)
+	}
+
+	var body io.Reader = resp.Body
+	if progress != nil {
+		body = &countingReader{r: resp.Body, progress: progress}
 	}
 
 	var syncResp SyncResponse
-	if err := json.NewDecoder(resp.Body).Decode(&syncResp); err != nil {
+	if err := json.NewDecoder(body).Decode(&syncResp); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
 	return &syncResp, nil
 }
+
+// countingReader reports how much has been read through it
+type countingReader struct {
+	r        io.Reader
+	n        int64
+	progress func(int64)
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	cr.progress(cr.n)
+	return n, err
+}
@@ -0,0 +1,254 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Feed tuning
+const (
+	longPollWait   = 30 * time.Second // How long the server may hold a poll
+	minPollSpacing = 5 * time.Second  // Floor between polls to servers that answer immediately
+	minBackoff     = time.Second
+	maxBackoff     = time.Minute
+)
+
+// errNoStream means the server has no SSE endpoint, so the feed falls back
+// to long polling
+var errNoStream = errors.New("server does not support change streams")
+
+// ChangeEvent is a batch of remote changes delivered by the feed
+type ChangeEvent struct {
+	Changes []Note
+	Cursor  string // Resume point: pass as since to get only newer changes
+	Err     error  // Set when the feed hit an error; it keeps retrying
+}
+
+// Feed streams remote changes newer than since until ctx is cancelled.
+//
+// It prefers a server-sent events stream (GET /api/sync/stream) and falls
+// back to long polling GET /api/sync?since=&wait= when the server has no
+// stream endpoint. Dropped connections are retried with exponential backoff
+// and resume from the last cursor, so no change is missed. Errors are
+// delivered as events with Err set; the channel closes only once ctx is
+// done, which also cancels the request in flight.
+func (c *Client) Feed(ctx context.Context, since string) <-chan ChangeEvent {
+	events := make(chan ChangeEvent, 16)
+
+	go func() {
+		defer close(events)
+
+		cursor := since
+		backoff := minBackoff
+		streaming := true
+
+		for ctx.Err() == nil {
+			var err error
+			if streaming {
+				err = c.stream(ctx, &cursor, events)
+				if errors.Is(err, errNoStream) {
+					streaming = false
+					continue
+				}
+			} else {
+				err = c.poll(ctx, &cursor, events)
+			}
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err == nil {
+				backoff = minBackoff
+				continue
+			}
+
+			if !send(ctx, events, ChangeEvent{Cursor: cursor, Err: err}) || !sleep(ctx, backoff) {
+				return
+			}
+			backoff = min(backoff*2, maxBackoff)
+		}
+	}()
+
+	return events
+}
+
+// stream reads server-sent events until the connection drops. Each event's
+// data is a sync response and its id the cursor after it. A clean close by
+// the server is not an error.
+func (c *Client) stream(ctx context.Context, cursor *string, events chan<- ChangeEvent) error {
+	started := time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/sync/stream?since="+url.QueryEscape(*cursor), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "text/event-stream")
+	c.setHeaders(req)
+	if *cursor != "" {
+		req.Header.Set("Last-Event-ID", *cursor)
+	}
+
+	// The stream stays open indefinitely, so no overall timeout
+	resp, err := (&http.Client{Transport: c.httpClient.Transport}).Do(req)
+	if err != nil {
+		return fmt.Errorf("stream request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		return errNoStream
+	}
+	if resp.StatusCode != http.StatusOK {
+		return newStatusError("stream", resp)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return errNoStream
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 32<<20) // Events carry full note content
+
+	var id string
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case line == "":
+			// Blank line dispatches the event
+			if data.Len() > 0 {
+				if err := dispatch(ctx, data.String(), id, cursor, events); err != nil {
+					return err
+				}
+			}
+			id = ""
+			data.Reset()
+
+		case strings.HasPrefix(line, ":"):
+			// Comment / keep-alive
+
+		case strings.HasPrefix(line, "id:"):
+			id = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("stream interrupted: %w", err)
+	}
+
+	// Serverless hosts close long-lived responses routinely; reconnect
+	// quietly, but not in a tight loop
+	if elapsed := time.Since(started); elapsed < minPollSpacing {
+		sleep(ctx, minPollSpacing-elapsed)
+	}
+	return nil
+}
+
+// dispatch decodes one SSE payload and advances the cursor
+func dispatch(ctx context.Context, data, id string, cursor *string, events chan<- ChangeEvent) error {
+	var syncResp SyncResponse
+	if err := json.Unmarshal([]byte(data), &syncResp); err != nil {
+		return fmt.Errorf("failed to decode stream event: %w", err)
+	}
+
+	next := syncResp.Timestamp
+	if id != "" {
+		next = id
+	}
+	if next != "" {
+		*cursor = next
+	}
+
+	if len(syncResp.Changes) > 0 {
+		send(ctx, events, ChangeEvent{Changes: syncResp.Changes, Cursor: *cursor})
+	}
+	return nil
+}
+
+// poll performs one long-poll request. Servers that don't understand wait=
+// answer immediately, so polls are spaced at least minPollSpacing apart.
+func (c *Client) poll(ctx context.Context, cursor *string, events chan<- ChangeEvent) error {
+	started := time.Now()
+
+	query := url.Values{}
+	if *cursor != "" {
+		query.Set("since", *cursor)
+	}
+	query.Set("wait", fmt.Sprintf("%d", int(longPollWait.Seconds())))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/sync?"+query.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	c.setHeaders(req)
+
+	pollClient := &http.Client{
+		Transport: c.httpClient.Transport,
+		Timeout:   longPollWait + c.httpClient.Timeout,
+	}
+	resp, err := pollClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("poll request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newStatusError("poll", resp)
+	}
+
+	var syncResp SyncResponse
+	if err := json.NewDecoder(resp.Body).Decode(&syncResp); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if syncResp.Timestamp != "" {
+		*cursor = syncResp.Timestamp
+	}
+	if len(syncResp.Changes) > 0 {
+		send(ctx, events, ChangeEvent{Changes: syncResp.Changes, Cursor: *cursor})
+	}
+
+	if elapsed := time.Since(started); elapsed < minPollSpacing {
+		sleep(ctx, minPollSpacing-elapsed)
+	}
+	return nil
+}
+
+// send delivers an event unless the feed is being stopped
+func send(ctx context.Context, events chan<- ChangeEvent, ev ChangeEvent) bool {
+	select {
+	case events <- ev:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// sleep waits for d or until ctx is cancelled, reporting whether the full
+// duration passed
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
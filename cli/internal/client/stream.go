@@ -0,0 +1,204 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/daphen/notes-cli/internal/device"
+)
+
+// DefaultMaxBodyBytes keeps each push below the 4.5 MB request body limit
+// of serverless hosts like Vercel, with some headroom for JSON framing.
+const DefaultMaxBodyBytes = 4 << 20
+
+// Capabilities describes optional features the server supports
+type Capabilities struct {
+	Encodings    []string `json:"encodings"`    // Request encodings, e.g. "gzip"
+	MaxBodyBytes int64    `json:"maxBodyBytes"` // Largest accepted request body, 0 = unknown
+}
+
+// Supports reports whether the server accepts the given request encoding
+func (c *Capabilities) Supports(encoding string) bool {
+	return slices.Contains(c.Encodings, encoding)
+}
+
+// Capabilities asks the server which optional features it supports.
+// Servers without the endpoint (404) get the conservative defaults: plain
+// JSON bodies and DefaultMaxBodyBytes. Only a definite answer is cached on
+// the client; auth and server errors are returned so the next call asks
+// again. Safe for concurrent use.
+func (c *Client) Capabilities(ctx context.Context) (*Capabilities, error) {
+	c.capsMu.Lock()
+	defer c.capsMu.Unlock()
+
+	if c.caps != nil {
+		return c.caps, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/capabilities", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	c.setHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("capabilities request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	caps := &Capabilities{}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if err := json.NewDecoder(resp.Body).Decode(caps); err != nil {
+			return nil, fmt.Errorf("failed to decode capabilities: %w", err)
+		}
+	case http.StatusNotFound:
+		// An older server: no extras
+	default:
+		return nil, newStatusError("capabilities", resp)
+	}
+
+	c.caps = caps
+	return caps, nil
+}
+
+// encodeSyncRequest streams the request as JSON, one note at a time, so the
+// whole vault never has to sit in memory as a single marshaled body. The
+// encoding goroutine runs until the reader is drained or closed.
+func encodeSyncRequest(reqBody SyncRequest, compress bool) *io.PipeReader {
+	// 🔵 GO CONCEPT: io.Pipe
+	// A pipe connects a writer and a reader in memory. The goroutine writes
+	// JSON into one end while the HTTP client reads (and sends) from the other.
+	pr, pw := io.Pipe()
+
+	go func() {
+		var w io.Writer = pw
+		var gz *gzip.Writer
+		if compress {
+			gz = gzip.NewWriter(pw)
+			w = gz
+		}
+
+		err := writeSyncRequest(w, reqBody)
+		if err == nil && gz != nil {
+			err = gz.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return pr
+}
+
+// writeSyncRequest writes the same JSON that json.Marshal(reqBody) would,
+// encoding each note separately
+func writeSyncRequest(w io.Writer, reqBody SyncRequest) error {
+	clientID, err := json.Marshal(reqBody.ClientID)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, `{"clientId":%s,`, clientID); err != nil {
+		return err
+	}
+
+	if reqBody.Device != nil {
+		dev, err := json.Marshal(reqBody.Device)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, `"device":%s,`, dev); err != nil {
+			return err
+		}
+	}
+
+	if _, err := io.WriteString(w, `"changes":[`); err != nil {
+		return err
+	}
+
+	for i, n := range reqBody.Changes {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		data, err := json.Marshal(n)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", n.Path, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	_, err = io.WriteString(w, "]}")
+	return err
+}
+
+// ChunkLimit returns how many bytes of encoded notes a single push may
+// carry: the largest request body, less the request's own JSON framing
+func (c *Client) ChunkLimit(ctx context.Context) (int64, error) {
+	caps, err := c.Capabilities(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	maxBytes := int64(DefaultMaxBodyBytes)
+	if caps.MaxBodyBytes > 0 && caps.MaxBodyBytes < maxBytes {
+		maxBytes = caps.MaxBodyBytes
+	}
+	return maxBytes - c.framingSize(), nil
+}
+
+// framingSize returns the size of a push request with no notes in it
+func (c *Client) framingSize() int64 {
+	reqBody := SyncRequest{ClientID: c.clientID}
+	if c.device != (device.Info{}) {
+		reqBody.Device = &c.device
+	}
+
+	var buf bytes.Buffer
+	if err := writeSyncRequest(&buf, reqBody); err != nil {
+		return 0
+	}
+	return int64(buf.Len())
+}
+
+// SplitChunks groups notes into chunks whose encoded notes stay under
+// maxBytes, as returned by ChunkLimit. A single note larger than maxBytes
+// gets a chunk of its own.
+func SplitChunks(notes []Note, maxBytes int64) [][]Note {
+	var chunks [][]Note
+	var current []Note
+	var size int64
+
+	for _, n := range notes {
+		noteSize := encodedSize(n)
+		if len(current) > 0 && size+noteSize > maxBytes {
+			chunks = append(chunks, current)
+			current = nil
+			size = 0
+		}
+		current = append(current, n)
+		size += noteSize
+	}
+
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// encodedSize returns the JSON size of a note plus its separating comma
+func encodedSize(n Note) int64 {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return int64(len(n.Content))
+	}
+	return int64(len(data)) + 1
+}
@@ -6,8 +6,13 @@ package config
 // code inside it can only be imported by code within the parent module.
 
 import (
+	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
@@ -17,12 +22,107 @@ import (
 // Fields that start with uppercase are "exported" (public), lowercase are private.
 // The `toml:"..."` are struct tags - metadata used by the toml parser.
 type Config struct {
+	Version int `toml:"version"` // File format, see CurrentVersion
+
 	APIURL       string `toml:"api_url"`
 	AuthPassword string `toml:"auth_password"`
 	NotesDir     string `toml:"notes_dir"`
 	ClientID     string `toml:"client_id"`
+
+	// Safer alternatives to auth_password, see Password
+	AuthPasswordCommand string `toml:"auth_password_command"` // e.g. "pass show notes"
+	AuthKeyring         bool   `toml:"auth_keyring"`          // Secret Service keyring
+
+	// Glob patterns for notes that are never pushed, e.g. "drafts/"
+	Ignore []string `toml:"ignore"`
+
+	// Push tuning
+	PushWorkers   int     `toml:"push_workers"`    // Concurrent push requests
+	PushRateLimit float64 `toml:"push_rate_limit"` // Max requests per second, -1 = unlimited
+
+	// What to do with notes that look like they contain credentials:
+	// block, warn, redact or off
+	SecretScan string `toml:"secret_scan"`
+
+	// How local changes are noticed: auto, notify (inotify) or poll
+	WatchMode    string        `toml:"watch_mode"`
+	PollInterval time.Duration `toml:"poll_interval"` // Between scans when polling
+
+	// Diagnostic logging, shared by all vaults (see internal/logging)
+	LogLevel      string `toml:"log_level"`       // debug, info, warn, error
+	LogFormat     string `toml:"log_format"`      // text or json
+	LogFile       string `toml:"log_file"`        // "-" = stderr
+	LogMaxSize    int    `toml:"log_max_size"`    // MB before the file is rotated
+	LogMaxBackups int    `toml:"log_max_backups"` // Rotated files kept
+
+	// Commands run around syncs, see internal/hooks
+	Hooks Hooks `toml:"hooks"`
+
+	// host:port serving Prometheus metrics from -watch and the daemon,
+	// empty = off. Only loopback addresses are allowed.
+	MetricsAddr string `toml:"metrics_addr"`
+
+	// Named vaults, each synced to its own server and directory.
+	// The top-level settings above form the vault named "default".
+	DefaultVault string           `toml:"default_vault"`
+	Vaults       map[string]Vault `toml:"vaults"`
+
+	// Name of the vault this config was selected for (see Vault)
+	Name string `toml:"-"`
+
+	// Path of the config file, and where each setting came from
+	// (see Origin)
+	Path    string            `toml:"-"`
+	origins map[string]string `toml:"-"`
+
+	// Problems found while loading that shouldn't stop the program:
+	// unknown keys, upgrades, a file newer than this build
+	Warnings []string `toml:"-"`
+}
+
+// Vault is one [vaults.<name>] table. Empty fields fall back to the
+// top-level setting.
+type Vault struct {
+	APIURL              string   `toml:"api_url"`
+	AuthPassword        string   `toml:"auth_password"`
+	AuthPasswordCommand string   `toml:"auth_password_command"`
+	AuthKeyring         bool     `toml:"auth_keyring"`
+	NotesDir            string   `toml:"notes_dir"`
+	ClientID            string   `toml:"client_id"`
+	Ignore              []string `toml:"ignore"`
+	WatchMode           string   `toml:"watch_mode"`
+	SecretScan          string   `toml:"secret_scan"`
+	Hooks               Hooks    `toml:"hooks"`
 }
 
+// Hooks is the [hooks] table: shell commands run for each note synced.
+// A vault's own table overrides them one by one.
+type Hooks struct {
+	PrePush    string `toml:"pre_push"`
+	PostPull   string `toml:"post_pull"`
+	OnConflict string `toml:"on_conflict"`
+	OnCreate   string `toml:"on_create"`
+}
+
+// DefaultVaultName names the vault made of the top-level settings
+const DefaultVaultName = "default"
+
+// Defaults for optional settings
+const (
+	DefaultPushWorkers   = 4
+	DefaultPushRateLimit = 5.0
+
+	DefaultSecretScan = "block"
+
+	DefaultWatchMode    = "auto"
+	DefaultPollInterval = 2 * time.Second
+
+	DefaultLogLevel      = "info"
+	DefaultLogFormat     = "text"
+	DefaultLogMaxSize    = 10
+	DefaultLogMaxBackups = 3
+)
+
 // 🔵 GO CONCEPT: Error handling
 // Go doesn't have exceptions. Functions return errors as values.
 // The pattern is: (result, error) where error is nil on success.
@@ -41,13 +141,19 @@ func Load(configPath string) (*Config, error) {
 	// 'var cfg Config' declares a variable of type Config.
 	// Go automatically initializes it to the "zero value" (empty strings, 0 for numbers, etc.)
 
-	// Read the file
+	cfg.Path = configPath
+	cfg.origins = make(map[string]string)
+
+	// Read the file. A missing file is fine - everything can come from
+	// NOTES_* variables and flags instead (e.g. in a container).
 	data, err := os.ReadFile(configPath)
 	// 🔵 GO CONCEPT: Multiple return values
 	// := is shorthand for declaring and assigning. It infers the type.
 	// Functions often return (value, error) - this is the Go way.
 
-	if err != nil {
+	if os.IsNotExist(err) {
+		cfg.Path = ""
+	} else if err != nil {
 		// 🔵 GO CONCEPT: Error checking
 		// nil is like null in other languages. Always check if err != nil.
 		return nil, err
@@ -55,22 +161,97 @@ func Load(configPath string) (*Config, error) {
 	}
 
 	// Parse TOML into our struct
-	if err := toml.Unmarshal(data, &cfg); err != nil {
+	md, err := toml.Decode(string(data), &cfg)
+	if err != nil {
 		// 🔵 GO CONCEPT: The & operator
 		// & gets the address of a variable (makes a pointer).
-		// toml.Unmarshal needs a pointer so it can modify cfg.
-		return nil, err
+		// toml.Decode needs a pointer so it can modify cfg.
+		return nil, fmt.Errorf("%s: %w", configPath, err)
 	}
 
-	// Expand ~ in the notes directory path
-	if cfg.NotesDir != "" && cfg.NotesDir[0] == '~' {
-		home, err := os.UserHomeDir()
+	// Bring files written by older versions up to date
+	if version := max(cfg.Version, 1); cfg.Path != "" && version < CurrentVersion {
+		upgraded, steps := migrate(data, version)
+
+		backup, err := upgradeFile(configPath, data, upgraded, version)
 		if err != nil {
+			cfg.Warnings = append(cfg.Warnings, fmt.Sprintf(
+				"could not upgrade %s to version %d, using the upgrade for this run only: %v",
+				configPath, CurrentVersion, err))
+		} else {
+			cfg.Warnings = append(cfg.Warnings, fmt.Sprintf(
+				"upgraded %s from version %d to %d (backup: %s)", configPath, version, CurrentVersion, backup))
+		}
+		for _, step := range steps {
+			cfg.Warnings = append(cfg.Warnings, "  "+step)
+		}
+
+		warnings := cfg.Warnings
+		cfg = Config{Path: configPath, origins: cfg.origins, Warnings: warnings}
+		if md, err = toml.Decode(string(upgraded), &cfg); err != nil {
+			return nil, fmt.Errorf("%s: after upgrade: %w", configPath, err)
+		}
+	}
+	if cfg.Version > CurrentVersion {
+		cfg.Warnings = append(cfg.Warnings, fmt.Sprintf(
+			"%s is version %d, newer than this notes-cli understands (%d); some settings may be ignored",
+			configPath, cfg.Version, CurrentVersion))
+	}
+
+	// Keys the struct has no field for are most likely typos
+	for _, key := range md.Undecoded() {
+		cfg.Warnings = append(cfg.Warnings, unknownKey(configPath, key.String()))
+	}
+
+	for _, s := range settings {
+		if md.IsDefined(strings.Split(s.key, ".")...) {
+			cfg.origins[s.key] = configPath
+		}
+	}
+
+	// Expand ~ in the notes directory paths
+	if cfg.NotesDir, err = expandHome(cfg.NotesDir); err != nil {
+		return nil, err
+	}
+	if cfg.LogFile, err = expandHome(cfg.LogFile); err != nil {
+		return nil, err
+	}
+	for name, v := range cfg.Vaults {
+		if v.NotesDir, err = expandHome(v.NotesDir); err != nil {
 			return nil, err
 		}
-		cfg.NotesDir = filepath.Join(home, cfg.NotesDir[1:])
-		// 🔵 GO CONCEPT: String slicing
-		// cfg.NotesDir[1:] means "from index 1 to the end" (removes the ~)
+		// 🔵 GO CONCEPT: Map values are copies
+		// v is a copy of the map entry, so it has to be stored back
+		cfg.Vaults[name] = v
+	}
+
+	// Fill in optional settings that weren't set in the file
+	if cfg.PushWorkers <= 0 {
+		cfg.PushWorkers = DefaultPushWorkers
+	}
+	if cfg.PushRateLimit == 0 {
+		cfg.PushRateLimit = DefaultPushRateLimit
+	}
+	if cfg.SecretScan == "" {
+		cfg.SecretScan = DefaultSecretScan
+	}
+	if cfg.WatchMode == "" {
+		cfg.WatchMode = DefaultWatchMode
+	}
+	if !md.IsDefined("poll_interval") {
+		cfg.PollInterval = DefaultPollInterval
+	}
+	if cfg.LogLevel == "" {
+		cfg.LogLevel = DefaultLogLevel
+	}
+	if cfg.LogFormat == "" {
+		cfg.LogFormat = DefaultLogFormat
+	}
+	if !md.IsDefined("log_max_size") {
+		cfg.LogMaxSize = DefaultLogMaxSize
+	}
+	if !md.IsDefined("log_max_backups") {
+		cfg.LogMaxBackups = DefaultLogMaxBackups
 	}
 
 	return &cfg, nil
@@ -79,6 +260,135 @@ func Load(configPath string) (*Config, error) {
 	// The memory won't be deallocated - Go's garbage collector handles this.
 }
 
+// expandHome replaces a leading ~ with the user's home directory
+func expandHome(path string) (string, error) {
+	if path == "" || path[0] != '~' {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	// 🔵 GO CONCEPT: String slicing
+	// path[1:] means "from index 1 to the end" (removes the ~)
+	return filepath.Join(home, path[1:]), nil
+}
+
+// VaultNames lists the configured vaults, "default" first if the top-level
+// settings define one
+func (c *Config) VaultNames() []string {
+	var names []string
+	for name := range c.Vaults {
+		if name != DefaultVaultName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if _, ok := c.Vaults[DefaultVaultName]; ok || c.NotesDir != "" {
+		names = append([]string{DefaultVaultName}, names...)
+	}
+	return names
+}
+
+// Vault returns the settings for one vault, with unset fields inherited
+// from the top level. An empty name selects default_vault, the top-level
+// vault, or the only vault configured.
+func (c *Config) Vault(name string) (*Config, error) {
+	names := c.VaultNames()
+
+	if name == "" {
+		switch {
+		case c.DefaultVault != "":
+			name = c.DefaultVault
+		case len(names) == 0:
+			// Nothing in the file - notes_dir may still come from the
+			// environment or flags
+			name = DefaultVaultName
+		case len(names) == 1 || names[0] == DefaultVaultName:
+			name = names[0]
+		default:
+			return nil, fmt.Errorf("several vaults configured (%v): choose one with -vault", names)
+		}
+	}
+
+	vc := *c
+	vc.Name = name
+	vc.Vaults = nil
+	vc.origins = maps.Clone(c.origins)
+	if vc.origins == nil {
+		vc.origins = make(map[string]string)
+	}
+
+	v, ok := c.Vaults[name]
+	if !ok && name != DefaultVaultName {
+		return nil, fmt.Errorf("unknown vault %q (configured: %v)", name, names)
+	}
+
+	// Settings from the vault's table are attributed to it
+	from := fmt.Sprintf("%s [vaults.%s]", c.Path, name)
+	if v.APIURL != "" {
+		vc.APIURL = v.APIURL
+		vc.origins["api_url"] = from
+	}
+	switch {
+	case v.AuthPassword != "" || v.AuthPasswordCommand != "" || v.AuthKeyring:
+		// A vault with its own credentials doesn't inherit other sources
+		vc.AuthPassword = v.AuthPassword
+		vc.AuthPasswordCommand = v.AuthPasswordCommand
+		vc.AuthKeyring = v.AuthKeyring
+		vc.origins["auth_password"] = from
+		vc.origins["auth_password_command"] = from
+		vc.origins["auth_keyring"] = from
+	case v.APIURL != "" && v.APIURL != c.APIURL:
+		// The top-level password belongs to another server
+		vc.AuthPassword = ""
+		vc.AuthPasswordCommand = ""
+		vc.AuthKeyring = false
+		delete(vc.origins, "auth_password")
+		delete(vc.origins, "auth_password_command")
+		delete(vc.origins, "auth_keyring")
+	}
+	if v.NotesDir != "" {
+		vc.NotesDir = v.NotesDir
+		vc.origins["notes_dir"] = from
+	}
+	if v.ClientID != "" {
+		vc.ClientID = v.ClientID
+		vc.origins["client_id"] = from
+	}
+	if v.Ignore != nil {
+		vc.Ignore = v.Ignore
+		vc.origins["ignore"] = from
+	}
+	if v.SecretScan != "" {
+		vc.SecretScan = v.SecretScan
+		vc.origins["secret_scan"] = from
+	}
+	if v.WatchMode != "" {
+		vc.WatchMode = v.WatchMode
+		vc.origins["watch_mode"] = from
+	}
+	if v.Hooks.PrePush != "" {
+		vc.Hooks.PrePush = v.Hooks.PrePush
+		vc.origins["hooks.pre_push"] = from
+	}
+	if v.Hooks.PostPull != "" {
+		vc.Hooks.PostPull = v.Hooks.PostPull
+		vc.origins["hooks.post_pull"] = from
+	}
+	if v.Hooks.OnConflict != "" {
+		vc.Hooks.OnConflict = v.Hooks.OnConflict
+		vc.origins["hooks.on_conflict"] = from
+	}
+	if v.Hooks.OnCreate != "" {
+		vc.Hooks.OnCreate = v.Hooks.OnCreate
+		vc.origins["hooks.on_create"] = from
+	}
+
+	return &vc, nil
+}
+
 // DefaultConfigPath returns the default location for the config file
 func DefaultConfigPath() (string, error) {
 	home, err := os.UserHomeDir()
@@ -87,11 +397,3 @@ func DefaultConfigPath() (string, error) {
 	}
 	return filepath.Join(home, ".config", "notes-cli", "config.toml"), nil
 }
-
-// Example config file content
-const ExampleConfig = `# Notes CLI Configuration
-api_url = "http://localhost:3000"
-auth_password = "your-password-here"
-notes_dir = "~/personal/notes/storage"
-client_id = "linux-cli"
-`
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode"
+
+	"github.com/daphen/notes-cli/internal/keyring"
+)
+
+// PasswordEnv holds the default vault's server password in the
+// environment. A vault named work reads NOTES_AUTH_PASSWORD_WORK instead.
+const PasswordEnv = "NOTES_AUTH_PASSWORD"
+
+// Password resolves the server password for the vault, using the first of:
+//
+//  1. NOTES_AUTH_PASSWORD_<VAULT> (NOTES_AUTH_PASSWORD for the default
+//     vault)
+//  2. the first line printed by auth_password_command
+//  3. the Secret Service keyring, if auth_keyring is set
+//  4. auth_password
+//
+// It is resolved on demand rather than in Load, so commands that never talk
+// to the server don't run password managers.
+func (c *Config) Password() (string, error) {
+	env := c.passwordEnv()
+	if pw := os.Getenv(env); pw != "" {
+		return pw, nil
+	}
+
+	if c.AuthPasswordCommand != "" {
+		return runPasswordCommand(c.AuthPasswordCommand)
+	}
+
+	if c.AuthKeyring {
+		pw, err := keyring.Lookup(c.keyringName())
+		if err != nil {
+			return "", fmt.Errorf("auth_keyring: %w (store it with 'notes-cli -init')", err)
+		}
+		return pw, nil
+	}
+
+	if c.AuthPassword == "" {
+		return "", fmt.Errorf("no password configured: set auth_password_command, auth_keyring or %s", env)
+	}
+	return c.AuthPassword, nil
+}
+
+// IsPasswordEnv reports whether name is an environment variable Password
+// reads a password from: NOTES_AUTH_PASSWORD or NOTES_AUTH_PASSWORD_<VAULT>,
+// but not NOTES_AUTH_PASSWORD_COMMAND
+func IsPasswordEnv(name string) bool {
+	if name == PasswordEnv {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(name, PasswordEnv+"_")
+	return ok && suffix != "" && name != "NOTES_AUTH_PASSWORD_COMMAND"
+}
+
+// passwordEnv is the environment variable holding the vault's password.
+// Only the default vault reads the unsuffixed one: it would reach every
+// vault, and other vaults may talk to other servers.
+func (c *Config) passwordEnv() string {
+	if c.keyringName() == DefaultVaultName {
+		return PasswordEnv
+	}
+	return PasswordEnv + "_" + envSuffix(c.keyringName())
+}
+
+// keyringName is the vault attribute the password is stored under
+func (c *Config) keyringName() string {
+	if c.Name == "" {
+		return DefaultVaultName
+	}
+	return c.Name
+}
+
+// runPasswordCommand runs command through the shell and returns the first
+// line of its output. Stderr and stdin stay connected so tools like pass
+// can prompt for a GPG passphrase.
+func runPasswordCommand(command string) (string, error) {
+	var stdout bytes.Buffer
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("auth_password_command %q failed: %w", command, err)
+	}
+
+	password, _, _ := strings.Cut(stdout.String(), "\n")
+	password = strings.TrimRight(password, "\r")
+	if password == "" {
+		return "", fmt.Errorf("auth_password_command %q printed no password", command)
+	}
+	return password, nil
+}
+
+// envSuffix turns a vault name into an environment variable suffix
+func envSuffix(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, name)
+}
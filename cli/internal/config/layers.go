@@ -0,0 +1,528 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daphen/notes-cli/internal/device"
+	"github.com/daphen/notes-cli/internal/logging"
+)
+
+// Settings are layered, each overriding the one before:
+//
+//	defaults < config file (top level, then [vaults.<name>]) < NOTES_* env < flags
+//
+// Origin reports which layer a setting came from so errors and
+// 'config show --resolved' can point at it.
+
+// setting describes one key that can be overridden from the environment
+// and, unless flag is empty, the command line
+type setting struct {
+	key    string
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	vault  bool // picks a vault's server, password or directory
+	get    func(*Config) string
+	set    func(*Config, string) error
+}
+
+// 🔵 GO CONCEPT: Function values
+// get and set are closures, so one table drives env parsing, flag parsing
+// and printing without reflection.
+var settings = []setting{
+	{
+		key: "api_url", env: "NOTES_API_URL", flag: "api-url", vault: true,
+		usage: "Server URL",
+		get:   func(c *Config) string { return c.APIURL },
+		set:   func(c *Config, v string) error { c.APIURL = v; return nil },
+	},
+	{
+		// No flag: it would show up in ps. NOTES_AUTH_PASSWORD is read by
+		// Password instead, so it can also be set per vault.
+		key: "auth_password", secret: true,
+		get: func(c *Config) string { return c.AuthPassword },
+	},
+	{
+		key: "auth_password_command", env: "NOTES_AUTH_PASSWORD_COMMAND", flag: "auth-password-command", vault: true,
+		usage: "Command printing the server password",
+		get:   func(c *Config) string { return c.AuthPasswordCommand },
+		set:   func(c *Config, v string) error { c.AuthPasswordCommand = v; return nil },
+	},
+	{
+		key: "auth_keyring", env: "NOTES_AUTH_KEYRING", vault: true,
+		get: func(c *Config) string { return strconv.FormatBool(c.AuthKeyring) },
+		set: func(c *Config, v string) (err error) {
+			c.AuthKeyring, err = strconv.ParseBool(v)
+			return err
+		},
+	},
+	{
+		key: "notes_dir", env: "NOTES_DIR", flag: "notes-dir", vault: true,
+		usage: "Notes directory",
+		get:   func(c *Config) string { return c.NotesDir },
+		set: func(c *Config, v string) (err error) {
+			c.NotesDir, err = expandHome(v)
+			return err
+		},
+	},
+	{
+		key: "client_id", env: "NOTES_CLIENT_ID", flag: "client-id",
+		usage: "Client ID reported to the server",
+		get:   func(c *Config) string { return c.ClientID },
+		set:   func(c *Config, v string) error { c.ClientID = v; return nil },
+	},
+	{
+		key: "ignore", env: "NOTES_IGNORE", flag: "ignore",
+		usage: "Comma-separated ignore patterns (replaces the configured ones)",
+		get:   func(c *Config) string { return strings.Join(c.Ignore, ",") },
+		set: func(c *Config, v string) error {
+			c.Ignore = nil
+			for _, p := range strings.Split(v, ",") {
+				if p = strings.TrimSpace(p); p != "" {
+					c.Ignore = append(c.Ignore, p)
+				}
+			}
+			return nil
+		},
+	},
+	{
+		key: "push_workers", env: "NOTES_PUSH_WORKERS", flag: "push-workers",
+		usage: "Concurrent push requests",
+		get:   func(c *Config) string { return strconv.Itoa(c.PushWorkers) },
+		set: func(c *Config, v string) (err error) {
+			c.PushWorkers, err = strconv.Atoi(v)
+			return err
+		},
+	},
+	{
+		key: "push_rate_limit", env: "NOTES_PUSH_RATE_LIMIT", flag: "push-rate-limit",
+		usage: "Max push requests per second (-1 = unlimited)",
+		get:   func(c *Config) string { return strconv.FormatFloat(c.PushRateLimit, 'g', -1, 64) },
+		set: func(c *Config, v string) (err error) {
+			c.PushRateLimit, err = strconv.ParseFloat(v, 64)
+			return err
+		},
+	},
+	{
+		key: "secret_scan", env: "NOTES_SECRET_SCAN", flag: "secret-scan",
+		usage: "Notes with possible secrets: block, warn, redact, off",
+		get:   func(c *Config) string { return c.SecretScan },
+		set:   func(c *Config, v string) error { c.SecretScan = strings.ToLower(v); return nil },
+	},
+	{
+		key: "watch_mode", env: "NOTES_WATCH_MODE", flag: "watch-mode",
+		usage: "How changes are noticed: auto, notify, poll",
+		get:   func(c *Config) string { return c.WatchMode },
+		set:   func(c *Config, v string) error { c.WatchMode = strings.ToLower(v); return nil },
+	},
+	{
+		key: "poll_interval", env: "NOTES_POLL_INTERVAL",
+		get: func(c *Config) string { return c.PollInterval.String() },
+		set: func(c *Config, v string) (err error) {
+			c.PollInterval, err = time.ParseDuration(v)
+			return err
+		},
+	},
+	{
+		key: "log_level", env: "NOTES_LOG_LEVEL", flag: "log-level",
+		usage: "Log level: debug, info, warn, error",
+		get:   func(c *Config) string { return c.LogLevel },
+		set:   func(c *Config, v string) error { c.LogLevel = strings.ToLower(v); return nil },
+	},
+	{
+		key: "log_format", env: "NOTES_LOG_FORMAT", flag: "log-format",
+		usage: "Log format: text, json",
+		get:   func(c *Config) string { return c.LogFormat },
+		set:   func(c *Config, v string) error { c.LogFormat = strings.ToLower(v); return nil },
+	},
+	{
+		key: "log_file", env: "NOTES_LOG_FILE", flag: "log-file",
+		usage: "Log file, - for stderr",
+		get:   func(c *Config) string { return c.LogFile },
+		set: func(c *Config, v string) (err error) {
+			c.LogFile, err = expandHome(v)
+			return err
+		},
+	},
+	{
+		key: "log_max_size", env: "NOTES_LOG_MAX_SIZE",
+		get: func(c *Config) string { return strconv.Itoa(c.LogMaxSize) },
+		set: func(c *Config, v string) (err error) {
+			c.LogMaxSize, err = strconv.Atoi(v)
+			return err
+		},
+	},
+	{
+		key: "log_max_backups", env: "NOTES_LOG_MAX_BACKUPS",
+		get: func(c *Config) string { return strconv.Itoa(c.LogMaxBackups) },
+		set: func(c *Config, v string) (err error) {
+			c.LogMaxBackups, err = strconv.Atoi(v)
+			return err
+		},
+	},
+	// Hooks are only read from the file
+	{key: "hooks.pre_push", get: func(c *Config) string { return c.Hooks.PrePush }},
+	{key: "hooks.post_pull", get: func(c *Config) string { return c.Hooks.PostPull }},
+	{key: "hooks.on_conflict", get: func(c *Config) string { return c.Hooks.OnConflict }},
+	{key: "hooks.on_create", get: func(c *Config) string { return c.Hooks.OnCreate }},
+	{
+		key: "metrics_addr", env: "NOTES_METRICS_ADDR", flag: "metrics-addr",
+		usage: "Serve Prometheus metrics on this localhost address",
+		get:   func(c *Config) string { return c.MetricsAddr },
+		set:   func(c *Config, v string) error { c.MetricsAddr = v; return nil },
+	},
+}
+
+// secretScanModes are the valid secret_scan values
+var secretScanModes = []string{"block", "warn", "redact", "off"}
+
+// watchModes are the valid watch_mode values (see watcher.Mode)
+var watchModes = []string{"auto", "notify", "poll"}
+
+// VaultEnv selects the vault when -vault isn't given
+const VaultEnv = "NOTES_VAULT"
+
+// Setting is one resolved key, as printed by 'config show --resolved'
+type Setting struct {
+	Key    string
+	Value  string
+	Origin string
+	Secret bool
+}
+
+// Origin describes where a setting's value came from
+func (c *Config) Origin(key string) string {
+	if o, ok := c.origins[key]; ok {
+		return o
+	}
+	return "default"
+}
+
+// Settings lists every key with its value and origin
+func (c *Config) Settings() []Setting {
+	out := make([]Setting, 0, len(settings))
+	for _, s := range settings {
+		out = append(out, Setting{
+			Key:    s.key,
+			Value:  s.get(c),
+			Origin: c.Origin(s.key),
+			Secret: s.secret,
+		})
+	}
+	return out
+}
+
+// ApplyEnv overrides settings from NOTES_* environment variables
+func (c *Config) ApplyEnv() error {
+	for _, s := range settings {
+		if s.env == "" {
+			continue
+		}
+		v, ok := os.LookupEnv(s.env)
+		if !ok {
+			continue
+		}
+		if err := c.override(s, v, "env "+s.env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BindFlags registers a flag for each overridable setting. The values are
+// applied by ApplyFlags once a vault is chosen.
+func BindFlags(fs *flag.FlagSet) {
+	for _, s := range settings {
+		if s.flag != "" {
+			fs.String(s.flag, "", s.usage+" (overrides "+s.key+")")
+		}
+	}
+}
+
+// ApplyFlags overrides settings from flags registered by BindFlags that
+// were given on the command line
+func (c *Config) ApplyFlags(fs *flag.FlagSet) error {
+	byFlag := make(map[string]setting)
+	for _, s := range settings {
+		if s.flag != "" {
+			byFlag[s.flag] = s
+		}
+	}
+
+	var err error
+	// 🔵 GO CONCEPT: flag.Visit
+	// Visit only walks flags that were actually set, so an empty default
+	// never overrides the file.
+	fs.Visit(func(f *flag.Flag) {
+		if s, ok := byFlag[f.Name]; ok && err == nil {
+			err = c.override(s, f.Value.String(), "flag -"+f.Name)
+		}
+	})
+	return err
+}
+
+func (c *Config) override(s setting, value, origin string) error {
+	if err := s.set(c, value); err != nil {
+		return fmt.Errorf("%s: invalid %s %q: %w", origin, s.key, value, err)
+	}
+	if c.origins == nil {
+		c.origins = make(map[string]string)
+	}
+	c.origins[s.key] = origin
+	return nil
+}
+
+// Resolve selects a vault, layers the environment and flags on top of it
+// and validates the result
+func (c *Config) Resolve(name string, fs *flag.FlagSet) (*Config, error) {
+	vc, err := c.Layer(name, fs)
+	if err != nil {
+		return nil, err
+	}
+	if err := vc.Validate(); err != nil {
+		return nil, err
+	}
+	// Only the file is checked here: an override applies to the selected
+	// vault alone
+	if err := c.checkVaultDirs(c.Vault); err != nil {
+		return nil, err
+	}
+	return vc, nil
+}
+
+// CheckOverrides rejects environment and flag overrides of a vault's
+// server, password or directory for commands that run every vault at
+// once: a single value would reach all of them.
+func CheckOverrides(fs *flag.FlagSet) error {
+	given := make(map[string]bool)
+	if fs != nil {
+		fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	}
+
+	var errs []error
+	for _, s := range settings {
+		if !s.vault {
+			continue
+		}
+		if _, ok := os.LookupEnv(s.env); ok && s.env != "" {
+			errs = append(errs, fmt.Errorf("env %s would apply to every vault: choose one with -vault or set %s in its [vaults.<name>] table", s.env, s.key))
+		}
+		if given[s.flag] && s.flag != "" {
+			errs = append(errs, fmt.Errorf("flag -%s would apply to every vault: choose one with -vault", s.flag))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CheckVaultDirs makes sure no two vaults sync the same directory once
+// the environment and flags are layered on, for commands that run every
+// vault at once
+func (c *Config) CheckVaultDirs(fs *flag.FlagSet) error {
+	return c.checkVaultDirs(func(name string) (*Config, error) {
+		return c.Layer(name, fs)
+	})
+}
+
+// checkVaultDirs reports two vaults resolving to the same notes_dir. They
+// would share one sync state while pushing to different servers.
+func (c *Config) checkVaultDirs(vault func(name string) (*Config, error)) error {
+	seen := make(map[string]string)
+	for _, name := range c.VaultNames() {
+		vc, err := vault(name)
+		if err != nil || vc.NotesDir == "" {
+			// Reported when the vault is used
+			continue
+		}
+		dir, err := filepath.Abs(vc.NotesDir)
+		if err != nil {
+			continue
+		}
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = real
+		}
+		if other, ok := seen[dir]; ok {
+			return fmt.Errorf("notes_dir (from %s): vaults %s and %s both use %s, give each vault its own directory",
+				vc.Origin("notes_dir"), other, name, dir)
+		}
+		seen[dir] = name
+	}
+	return nil
+}
+
+// Global layers the environment and flags on top of the top-level
+// settings without choosing a vault, for settings shared by all vaults
+// such as logging and metrics. Only those are validated.
+func (c *Config) Global(fs *flag.FlagSet) (*Config, error) {
+	gc := *c
+	gc.origins = maps.Clone(c.origins)
+	if err := gc.ApplyEnv(); err != nil {
+		return nil, err
+	}
+	if fs != nil {
+		if err := gc.ApplyFlags(fs); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	gc.validateShared(func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s (from %s): %s", key, gc.Origin(key), fmt.Sprintf(format, args...)))
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return &gc, nil
+}
+
+// Layer is Resolve without validation
+func (c *Config) Layer(name string, fs *flag.FlagSet) (*Config, error) {
+	if name == "" {
+		name = os.Getenv(VaultEnv)
+	}
+
+	vc, err := c.Vault(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := vc.ApplyEnv(); err != nil {
+		return nil, err
+	}
+	if fs != nil {
+		if err := vc.ApplyFlags(fs); err != nil {
+			return nil, err
+		}
+	}
+
+	// Without a configured client ID the machine's device ID is used.
+	// Failing to create one is left to Validate to report.
+	if vc.ClientID == "" {
+		if id, err := device.ID(); err == nil {
+			path, _ := device.IDPath()
+			vc.ClientID = id
+			vc.origins["client_id"] = "device ID in " + path
+		}
+	}
+	return vc, nil
+}
+
+// Validate checks the settings a vault needs to sync. Errors name the
+// layer the bad value came from.
+func (c *Config) Validate() error {
+	var errs []error
+	bad := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s (from %s): %s", key, c.Origin(key), fmt.Sprintf(format, args...)))
+	}
+
+	if c.APIURL == "" {
+		bad("api_url", "not set")
+	} else if u, err := url.Parse(c.APIURL); err != nil {
+		bad("api_url", "%v", err)
+	} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		bad("api_url", "%q is not an http(s) URL", c.APIURL)
+	}
+
+	if c.NotesDir == "" {
+		bad("notes_dir", "not set")
+	} else if err := checkWritableDir(c.NotesDir); err != nil {
+		bad("notes_dir", "%v", err)
+	}
+
+	if strings.TrimSpace(c.ClientID) == "" {
+		bad("client_id", "must not be empty")
+	}
+
+	if c.PushWorkers < 1 {
+		bad("push_workers", "must be at least 1, got %d", c.PushWorkers)
+	}
+
+	if !slices.Contains(secretScanModes, c.SecretScan) {
+		bad("secret_scan", "must be one of %s, got %q", strings.Join(secretScanModes, ", "), c.SecretScan)
+	}
+
+	if !slices.Contains(watchModes, c.WatchMode) {
+		bad("watch_mode", "must be one of %s, got %q", strings.Join(watchModes, ", "), c.WatchMode)
+	}
+	if c.PollInterval <= 0 {
+		bad("poll_interval", "must be positive, got %s", c.PollInterval)
+	}
+
+	c.validateShared(bad)
+
+	// 🔵 GO CONCEPT: errors.Join
+	// Joins several errors into one (nil if there are none), so every
+	// problem is reported at once.
+	return errors.Join(errs...)
+}
+
+// validateShared checks the settings shared by all vaults: logging and
+// metrics
+func (c *Config) validateShared(bad func(key, format string, args ...any)) {
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		bad("log_level", "%v", err)
+	}
+	if err := logging.CheckFormat(c.LogFormat); err != nil {
+		bad("log_format", "%v", err)
+	}
+	if c.LogMaxSize < 0 {
+		bad("log_max_size", "must not be negative, got %d", c.LogMaxSize)
+	}
+	if c.LogMaxBackups < 0 {
+		bad("log_max_backups", "must not be negative, got %d", c.LogMaxBackups)
+	}
+
+	if c.MetricsAddr != "" {
+		if err := checkLoopback(c.MetricsAddr); err != nil {
+			bad("metrics_addr", "%v", err)
+		}
+	}
+}
+
+// checkLoopback makes sure addr is a host:port only reachable from this
+// machine - metrics show note activity, so they aren't served to the
+// network
+func checkLoopback(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("%q is not a loopback address (use 127.0.0.1 or localhost)", host)
+	}
+	return nil
+}
+
+// checkWritableDir makes sure dir exists and files can be created in it
+func checkWritableDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	f, err := os.CreateTemp(dir, ".notes-cli-write-test-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// CurrentVersion is the config file format written by this build.
+// Version 1 files have no version key.
+const CurrentVersion = 2
+
+// migration upgrades a file from version to-1 to version to. Migrations
+// edit the file's lines rather than re-encoding it, so comments and
+// layout survive.
+type migration struct {
+	to       int
+	describe string
+	apply    func(d *document) bool // Reports whether anything changed
+}
+
+// 🔵 GO CONCEPT: Slices as ordered tables
+// Migrations run in order, each one only for files older than its target.
+var migrations = []migration{
+	{
+		to:       2,
+		describe: "push_rate_limit = 0 written as the default rate it stands for; unlimited is -1",
+		apply: func(d *document) bool {
+			// 0 has loaded as the default rate since push_rate_limit was
+			// added, so spell that out rather than change what it does
+			if v, ok := d.get("push_rate_limit"); ok && (v == "0" || v == "0.0") {
+				d.set("push_rate_limit", fmt.Sprint(DefaultPushRateLimit))
+				return true
+			}
+			return false
+		},
+	},
+}
+
+// migrate upgrades data to CurrentVersion. It returns the new content and
+// a description of each step that changed something.
+func migrate(data []byte, version int) ([]byte, []string) {
+	d := newDocument(data)
+	var steps []string
+	for _, m := range migrations {
+		if version >= m.to {
+			continue
+		}
+		if m.apply(d) {
+			steps = append(steps, fmt.Sprintf("v%d: %s", m.to, m.describe))
+		}
+	}
+	d.set("version", fmt.Sprint(CurrentVersion))
+	return d.bytes(), steps
+}
+
+// upgradeFile writes a migrated config over path, keeping the original as
+// path.v<version>.bak. The file's permissions are preserved.
+func upgradeFile(path string, old, data []byte, version int) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	backup := fmt.Sprintf("%s.v%d.bak", path, version)
+	if err := os.WriteFile(backup, old, info.Mode().Perm()); err != nil {
+		return "", fmt.Errorf("failed to back up config: %w", err)
+	}
+
+	// Write next to the original and rename, so a crash never leaves a
+	// half-written config
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".config-*.toml")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return backup, os.Rename(tmp.Name(), path)
+}
+
+// document is a config file as lines, with just enough TOML awareness to
+// read and replace top-level keys
+type document struct {
+	lines []string
+}
+
+var keyLine = regexp.MustCompile(`^\s*([A-Za-z0-9_-]+)\s*=\s*(.*?)\s*(#.*)?$`)
+
+func newDocument(data []byte) *document {
+	return &document{lines: strings.Split(string(data), "\n")}
+}
+
+func (d *document) bytes() []byte {
+	return []byte(strings.Join(d.lines, "\n"))
+}
+
+// topLevel returns the number of lines before the first [table]
+func (d *document) topLevel() int {
+	for i, line := range d.lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "[") {
+			return i
+		}
+	}
+	return len(d.lines)
+}
+
+// find returns the index of a top-level key's line, or -1
+func (d *document) find(key string) int {
+	for i := range d.topLevel() {
+		if m := keyLine.FindStringSubmatch(d.lines[i]); m != nil && m[1] == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// get returns a top-level key's raw TOML value
+func (d *document) get(key string) (string, bool) {
+	i := d.find(key)
+	if i < 0 {
+		return "", false
+	}
+	return keyLine.FindStringSubmatch(d.lines[i])[2], true
+}
+
+// set replaces a top-level key's raw TOML value, keeping any trailing
+// comment, or adds the key after the file's leading comments
+func (d *document) set(key, value string) {
+	if i := d.find(key); i >= 0 {
+		m := keyLine.FindStringSubmatch(d.lines[i])
+		line := key + " = " + value
+		if m[3] != "" {
+			line += " " + m[3]
+		}
+		d.lines[i] = line
+		return
+	}
+
+	at := 0
+	for at < len(d.lines) && strings.HasPrefix(strings.TrimSpace(d.lines[at]), "#") {
+		at++
+	}
+	d.lines = slices.Insert(d.lines, at, key+" = "+value)
+}
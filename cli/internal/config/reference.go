@@ -0,0 +1,202 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// option documents one config key for the reference config. Keys with
+// required set are written uncommented.
+type option struct {
+	key      string
+	doc      string
+	example  string
+	required bool
+}
+
+// options lists every top-level key in the order the reference shows them
+var options = []option{
+	{key: "version", example: fmt.Sprint(CurrentVersion), required: true,
+		doc: "Config file format. Older files are upgraded automatically (with a backup), so leave it alone."},
+	{key: "api_url", example: `"http://localhost:3000"`, required: true,
+		doc: "Notes server URL."},
+	{key: "auth_password", example: `"your-password-here"`, required: true,
+		doc: "Server password. NOTES_AUTH_PASSWORD (NOTES_AUTH_PASSWORD_<VAULT> outside the default vault) overrides it, and the two options below keep it out of this file."},
+	{key: "auth_password_command", example: `"pass show notes"`,
+		doc: "Command whose first line of output is the password."},
+	{key: "auth_keyring", example: "true",
+		doc: "Read the password from the Secret Service keyring (stored by -init)."},
+	{key: "notes_dir", example: `"~/personal/notes/storage"`, required: true,
+		doc: "Directory holding the markdown notes."},
+	{key: "client_id", example: `"linux-cli"`,
+		doc: "Name this machine reports to the server with every request, alongside its hostname, OS and notes-cli version. Defaults to a UUID generated once per machine."},
+	{key: "ignore", example: `["drafts/", "*.tmp.md"]`,
+		doc: "Notes that are never pushed. A pattern without a slash matches file names at any depth, \"dir/\" everything below dir."},
+	{key: "push_workers", example: fmt.Sprint(DefaultPushWorkers),
+		doc: "Concurrent push requests."},
+	{key: "push_rate_limit", example: fmt.Sprint(DefaultPushRateLimit),
+		doc: "Push requests per second across all workers, -1 = unlimited."},
+	{key: "secret_scan", example: `"` + DefaultSecretScan + `"`,
+		doc: "What to do when a note about to be pushed looks like it contains a secret (AWS keys, GitHub tokens, private keys, random-looking strings): block skips the push, warn logs and pushes anyway, redact replaces the secret in the note and pushes that, off doesn't scan. Add notes-cli:allow-secret to a line for false positives."},
+	{key: "watch_mode", example: `"` + DefaultWatchMode + `"`,
+		doc: "How local changes are noticed. notify uses inotify; poll scans the directory every poll_interval, for network and FUSE filesystems (NFS, SMB, sshfs) where inotify misses changes. auto polls on those and when inotify can't be set up, and uses inotify otherwise."},
+	{key: "poll_interval", example: `"` + DefaultPollInterval.String() + `"`,
+		doc: "Time between scans when polling."},
+	{key: "log_level", example: `"` + DefaultLogLevel + `"`,
+		doc: "Diagnostic log level: debug, info, warn or error. debug also logs every server request."},
+	{key: "log_format", example: `"` + DefaultLogFormat + `"`,
+		doc: "Log format: text or json."},
+	{key: "log_file", example: `"~/.local/state/notes-cli/notes-cli.log"`,
+		doc: "Log file, shared by all vaults. \"-\" logs to stderr, e.g. for the systemd journal. Defaults to notes-cli.log in the state directory, so the TUI is never drawn over."},
+	{key: "log_max_size", example: fmt.Sprint(DefaultLogMaxSize),
+		doc: "Megabytes after which the log file is rotated to notes-cli.log.1, 0 = never."},
+	{key: "log_max_backups", example: fmt.Sprint(DefaultLogMaxBackups),
+		doc: "Rotated log files kept."},
+	{key: "hooks.pre_push", example: `"prettier --check \"$NOTE_FILE\""`,
+		doc: "Shell command run in notes_dir before a note is pushed, with NOTE_PATH, NOTE_FILE, NOTE_TITLE, NOTE_ACTION, NOTES_VAULT and NOTES_HOOK set and the same as JSON on stdin. A non-zero exit (or taking over 30s) skips the push; the first line of output says why. Changes it makes to the note are pushed."},
+	{key: "hooks.post_pull", example: `"notify-send \"Pulled $NOTE_TITLE\""`,
+		doc: "Run after a note from the server was written or deleted."},
+	{key: "hooks.on_conflict", example: `"notify-send \"Conflict in $NOTE_PATH\""`,
+		doc: "Run when a note changed both here and on the server."},
+	{key: "hooks.on_create", example: `"notify-send \"New note: $NOTE_TITLE\""`,
+		doc: "Run after a note created here was first pushed by the watcher."},
+	{key: "metrics_addr", example: `"127.0.0.1:9464"`,
+		doc: "Serve Prometheus metrics at http://<addr>/metrics while -watch or the daemon runs. Only loopback addresses are allowed."},
+	{key: "default_vault", example: `"default"`,
+		doc: "Vault used when -vault isn't given. The top-level settings form the vault named \"default\"."},
+}
+
+// vaultKeys are the keys allowed in a [vaults.<name>] table
+var vaultKeys = []string{
+	"api_url", "auth_password", "auth_password_command", "auth_keyring",
+	"notes_dir", "client_id", "ignore", "secret_scan", "watch_mode",
+	"hooks.pre_push", "hooks.post_pull", "hooks.on_conflict", "hooks.on_create",
+}
+
+// Reference returns a config file documenting every option. Required
+// keys are set to example values, the rest are commented out.
+func Reference() string {
+	var b strings.Builder
+	b.WriteString("# notes-cli configuration\n")
+	b.WriteString("#\n")
+	b.WriteString("# Settings are layered: defaults < this file < NOTES_* environment < flags.\n")
+	b.WriteString("# 'notes-cli config show --resolved' prints the result.\n")
+
+	for _, o := range options {
+		b.WriteString("\n")
+		for _, line := range wrap(o.doc, 74) {
+			b.WriteString("# " + line + "\n")
+		}
+		if s := lookupSetting(o.key); s != nil && (s.env != "" || s.flag != "") {
+			var via []string
+			if s.env != "" {
+				via = append(via, "env "+s.env)
+			}
+			if s.flag != "" {
+				via = append(via, "flag -"+s.flag)
+			}
+			b.WriteString("# Override: " + strings.Join(via, ", ") + "\n")
+		}
+
+		line := o.key + " = " + o.example
+		if !o.required {
+			line = "# " + line
+		}
+		b.WriteString(line + "\n")
+	}
+
+	b.WriteString(`
+# More vaults, each synced to its own server and directory and selected
+# with -vault <name>. Keys left out are inherited from the top level;
+# credentials are inherited only if the vault sets none of its own.
+#
+# [vaults.work]
+# api_url = "https://notes.example.com"
+# auth_password_command = "pass show notes/work"
+# notes_dir = "~/work/notes"
+# client_id = "work-laptop"
+# ignore = ["scratch/"]
+# watch_mode = "poll"
+# hooks.on_create = "notify-send \"New work note\""
+`)
+	return b.String()
+}
+
+func lookupSetting(key string) *setting {
+	for i := range settings {
+		if settings[i].key == key {
+			return &settings[i]
+		}
+	}
+	return nil
+}
+
+// wrap splits text into lines of at most width characters
+func wrap(text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	return append(lines, line)
+}
+
+// unknownKey describes a key the config has no use for, suggesting the
+// closest known one
+func unknownKey(path, key string) string {
+	known := make([]string, 0, len(options))
+	for _, o := range options {
+		known = append(known, o.key)
+	}
+
+	// Keys inside a vault table are compared with the vault keys
+	name := key
+	if strings.HasPrefix(key, "vaults.") {
+		if parts := strings.SplitN(key, ".", 3); len(parts) == 3 {
+			name = parts[2]
+			known = vaultKeys
+		}
+	}
+
+	msg := fmt.Sprintf("%s: unknown key %q", path, key)
+	best, bestDist := "", 3 // Only suggest near misses
+	for _, k := range known {
+		if d := editDistance(name, k); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	if best != "" {
+		msg += fmt.Sprintf(" (did you mean %q?)", best)
+	}
+	return msg
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		// 🔵 GO CONCEPT: Parallel assignment
+		// Swaps the two rows without a temporary variable.
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,114 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// Client talks to a running daemon
+type Client struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+
+	mu     sync.Mutex
+	nextID int
+}
+
+// Dial connects to the daemon's control socket
+func Dial(path string) (*Client, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("no daemon running at %s: %w", path, err)
+	}
+	return &Client{conn: conn, scanner: bufio.NewScanner(conn)}, nil
+}
+
+// Close hangs up
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Call sends a request and decodes its result into result (unless nil)
+func (c *Client) Call(method string, params, result any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nextID++
+	id := json.RawMessage(strconv.Itoa(c.nextID))
+	if err := c.send(method, id, params); err != nil {
+		return err
+	}
+
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *Error          `json:"error"`
+	}
+	if err := c.read(&resp); err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result != nil {
+		return json.Unmarshal(resp.Result, result)
+	}
+	return nil
+}
+
+// Status asks for the daemon's status
+func (c *Client) Status() (Status, error) {
+	var st Status
+	err := c.Call(MethodStatus, nil, &st)
+	return st, err
+}
+
+// Tail calls fn for every event until the connection closes. The client
+// can't be used for other calls afterwards.
+func (c *Client) Tail(fn func(Event)) error {
+	if err := c.Call(MethodTailEvents, nil, nil); err != nil {
+		return err
+	}
+
+	for {
+		var n struct {
+			Method string `json:"method"`
+			Params Event  `json:"params"`
+		}
+		if err := c.read(&n); err != nil {
+			return err
+		}
+		if n.Method == "event" {
+			fn(n.Params)
+		}
+	}
+}
+
+func (c *Client) send(method string, id json.RawMessage, params any) error {
+	req := struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Method  string          `json:"method"`
+		Params  any             `json:"params,omitempty"`
+	}{"2.0", id, method, params}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Write(append(data, '\n'))
+	return err
+}
+
+func (c *Client) read(v any) error {
+	if !c.scanner.Scan() {
+		if err := c.scanner.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("daemon closed the connection")
+	}
+	return json.Unmarshal(c.scanner.Bytes(), v)
+}
@@ -0,0 +1,138 @@
+package daemon
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/daphen/notes-cli/internal/state"
+)
+
+// The control API is JSON-RPC 2.0 over a Unix socket, one JSON object per
+// line in each direction. tail-events answers once and then keeps sending
+// "event" notifications until the client hangs up:
+//
+//	→ {"jsonrpc":"2.0","id":1,"method":"pause","params":{"vault":"work"}}
+//	← {"jsonrpc":"2.0","id":1,"result":{}}
+
+// Methods understood by the daemon
+const (
+	MethodStatus     = "status"
+	MethodPause      = "pause"
+	MethodResume     = "resume"
+	MethodSyncNow    = "sync-now"
+	MethodTailEvents = "tail-events"
+)
+
+// Standard JSON-RPC error codes
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Request is a JSON-RPC request. Requests without an ID are notifications
+// and get no response.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response answers a Request with either Result or Error
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Notification is sent by the daemon without a request, e.g. events
+type Notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+// Error is a JSON-RPC error object
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// VaultParams selects the vault a method acts on. Empty means all vaults.
+type VaultParams struct {
+	Vault string `json:"vault,omitempty"`
+
+	// For pause: seconds after which the daemon resumes by itself unless
+	// paused again, so a client that dies can't leave it paused. 0 pauses
+	// until resume.
+	Lease int `json:"lease,omitempty"`
+}
+
+// Status is the answer to "status"
+type Status struct {
+	PID     int           `json:"pid"`
+	Started time.Time     `json:"started"`
+	Version string        `json:"version"`
+	Vaults  []VaultStatus `json:"vaults"`
+}
+
+// VaultStatus describes one vault's sync loop
+type VaultStatus struct {
+	Name      string    `json:"name"`
+	NotesDir  string    `json:"notesDir"`
+	Paused    bool      `json:"paused"`
+	Pending   int       `json:"pending"` // Changes held back while paused
+	Conflicts int       `json:"conflicts"`
+	Pushed    int       `json:"pushed"`
+	Pulled    int       `json:"pulled"`
+	LastSync  time.Time `json:"lastSync,omitzero"`
+	LastError string    `json:"lastError,omitempty"`
+}
+
+// Kinds of Event
+const (
+	EventPush     = "push"     // A change was sent to the server
+	EventPull     = "pull"     // A server change was written to disk
+	EventSkip     = "skip"     // A change was refused locally
+	EventConflict = "conflict" // A server change collided with local edits
+	EventError    = "error"
+	EventPaused   = "paused"
+	EventResumed  = "resumed"
+	EventSync     = "sync" // A sync-now pass finished
+)
+
+// Event is something a sync loop did, streamed by "tail-events"
+type Event struct {
+	Time    time.Time `json:"time"`
+	Vault   string    `json:"vault"`
+	Kind    string    `json:"kind"`
+	Path    string    `json:"path,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// SocketPath returns where the daemon listens: $NOTES_DAEMON_SOCKET,
+// $XDG_RUNTIME_DIR/notes-cli.sock, or the state directory
+func SocketPath() (string, error) {
+	if path := os.Getenv("NOTES_DAEMON_SOCKET"); path != "" {
+		return path, nil
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "notes-cli.sock"), nil
+	}
+
+	dir, err := state.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "daemon.sock"), nil
+}
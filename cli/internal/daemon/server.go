@@ -0,0 +1,223 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Handler carries out the control methods. Vault names are empty for
+// "all vaults".
+type Handler interface {
+	Status() Status
+	Pause(vault string, lease time.Duration) error
+	Resume(vault string) error
+	SyncNow(vault string) error
+}
+
+// ErrRunning means another daemon already owns the socket
+var ErrRunning = errors.New("a notes-cli daemon is already running")
+
+// Server answers control requests on a Unix socket and fans events out to
+// tail-events subscribers
+type Server struct {
+	path    string
+	handler Handler
+	ln      net.Listener
+
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+// Listen creates the control socket. A socket left behind by a daemon that
+// died is removed; a live one yields ErrRunning.
+func Listen(path string, h Handler) (*Server, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("%w (%s)", ErrRunning, path)
+	}
+	os.Remove(path)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Only this user may drive the daemon
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	return &Server{
+		path:    path,
+		handler: h,
+		ln:      ln,
+		subs:    make(map[chan Event]struct{}),
+	}, nil
+}
+
+// Path returns the socket path
+func (s *Server) Path() string {
+	return s.path
+}
+
+// Serve accepts connections until Close is called
+func (s *Server) Serve() error {
+	for {
+		conn, err := s.ln.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops accepting connections and removes the socket
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	os.Remove(s.path)
+	return err
+}
+
+// Publish sends an event to every tail-events subscriber. Subscribers that
+// fall behind miss events rather than stalling the sync loops.
+func (s *Server) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subs {
+		// 🔵 GO CONCEPT: Non-blocking send
+		// select with a default case never waits: if the buffer is full
+		// the event is dropped for that subscriber.
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+func (s *Server) subscribe() chan Event {
+	ch := make(chan Event, 64)
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	s.mu.Unlock()
+	return ch
+}
+
+func (s *Server) unsubscribe(ch chan Event) {
+	s.mu.Lock()
+	delete(s.subs, ch)
+	s.mu.Unlock()
+}
+
+// serveConn answers requests on one connection, one per line
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	enc := json.NewEncoder(conn)
+
+	for scanner.Scan() {
+		var req Request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			enc.Encode(Response{JSONRPC: "2.0", Error: &Error{Code: CodeParseError, Message: err.Error()}})
+			continue
+		}
+
+		if req.Method == MethodTailEvents {
+			if req.ID != nil {
+				enc.Encode(Response{JSONRPC: "2.0", ID: req.ID, Result: struct{}{}})
+			}
+			s.tail(conn, enc)
+			return
+		}
+
+		result, rpcErr := s.dispatch(req)
+		if req.ID == nil {
+			continue // Notification: no answer
+		}
+		resp := Response{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rpcErr}
+		if rpcErr == nil && result == nil {
+			resp.Result = struct{}{}
+		}
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) dispatch(req Request) (any, *Error) {
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return nil, &Error{Code: CodeInvalidRequest, Message: "not a JSON-RPC 2.0 request"}
+	}
+
+	var params VaultParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+		}
+	}
+
+	var err error
+	switch req.Method {
+	case MethodStatus:
+		return s.handler.Status(), nil
+	case MethodPause:
+		err = s.handler.Pause(params.Vault, time.Duration(params.Lease)*time.Second)
+	case MethodResume:
+		err = s.handler.Resume(params.Vault)
+	case MethodSyncNow:
+		err = s.handler.SyncNow(params.Vault)
+	default:
+		return nil, &Error{Code: CodeMethodNotFound, Message: "unknown method " + req.Method}
+	}
+
+	if err != nil {
+		return nil, &Error{Code: CodeInternalError, Message: err.Error()}
+	}
+	return nil, nil
+}
+
+// tail streams events to conn until the client disconnects
+func (s *Server) tail(conn net.Conn, enc *json.Encoder) {
+	ch := s.subscribe()
+	defer s.unsubscribe(ch)
+
+	// The client sends nothing more; a read returning means it hung up
+	gone := make(chan struct{})
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				close(gone)
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case e := <-ch:
+			if err := enc.Encode(Notification{JSONRPC: "2.0", Method: "event", Params: e}); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package device
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/daphen/notes-cli/internal/state"
+)
+
+// Info describes the machine requests come from, so the server's sync log
+// can tell devices apart
+type Info struct {
+	Hostname string `json:"hostname,omitempty"`
+	OS       string `json:"os"`      // GOOS/GOARCH, e.g. linux/amd64
+	Version  string `json:"version"` // notes-cli build
+}
+
+// Current describes this machine running the given notes-cli version
+func Current(version string) Info {
+	hostname, _ := os.Hostname()
+	return Info{
+		Hostname: hostname,
+		OS:       runtime.GOOS + "/" + runtime.GOARCH,
+		Version:  version,
+	}
+}
+
+// IDPath returns the file holding this machine's device ID
+func IDPath() (string, error) {
+	dir, err := state.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "device-id"), nil
+}
+
+// ID returns a random UUID identifying this machine, generated on first
+// use and kept in the state directory so it stays the same across runs
+func ID() (string, error) {
+	path, err := IDPath()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if id := strings.TrimSpace(string(data)); id != "" {
+			return id, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read device ID: %w", err)
+	}
+
+	id, err := newUUID()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("failed to save device ID: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(id+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to save device ID: %w", err)
+	}
+	return id, nil
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	// 🔵 GO CONCEPT: Bit masks
+	// Set the version (4) and variant (10xx) bits required by RFC 9562.
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,232 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind marks a line as shared, removed or added
+type Kind byte
+
+const (
+	Equal  Kind = ' '
+	Delete Kind = '-' // Only in the old (local) text
+	Insert Kind = '+' // Only in the new (remote) text
+)
+
+// Line is one line of a diff
+type Line struct {
+	Kind Kind
+	Text string
+}
+
+// maxCells bounds the LCS table; larger inputs fall back to a whole-file
+// replacement rather than using lots of memory
+const maxCells = 4_000_000
+
+// Lines computes a line diff between a and b using the longest common
+// subsequence. Notes are small, so the simple O(n*m) table is fine.
+func Lines(a, b string) []Line {
+	x := splitLines(a)
+	y := splitLines(b)
+
+	if len(x)*len(y) > maxCells {
+		var out []Line
+		for _, l := range x {
+			out = append(out, Line{Delete, l})
+		}
+		for _, l := range y {
+			out = append(out, Line{Insert, l})
+		}
+		return out
+	}
+
+	// lcs[i][j] = length of the LCS of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []Line
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			out = append(out, Line{Equal, x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, Line{Delete, x[i]})
+			i++
+		default:
+			out = append(out, Line{Insert, y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		out = append(out, Line{Delete, x[i]})
+	}
+	for ; j < len(y); j++ {
+		out = append(out, Line{Insert, y[j]})
+	}
+
+	return out
+}
+
+// Unified renders a diff in unified format with the given lines of context
+func Unified(a, b, nameA, nameB string, context int) string {
+	lines := Lines(a, b)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", nameA, nameB)
+
+	for _, h := range hunks(lines, context) {
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", h.startA, h.countA, h.startB, h.countB)
+		for _, l := range h.lines {
+			sb.WriteByte(byte(l.Kind))
+			sb.WriteString(l.Text)
+			sb.WriteByte('\n')
+		}
+	}
+
+	return sb.String()
+}
+
+// Merge returns a merge buffer: shared lines as-is and every differing
+// region wrapped in git-style conflict markers
+func Merge(local, remote, nameLocal, nameRemote string) string {
+	var sb strings.Builder
+	var ours, theirs []string
+
+	flush := func() {
+		if len(ours) == 0 && len(theirs) == 0 {
+			return
+		}
+		sb.WriteString("<<<<<<< " + nameLocal + "\n")
+		for _, l := range ours {
+			sb.WriteString(l + "\n")
+		}
+		sb.WriteString("=======\n")
+		for _, l := range theirs {
+			sb.WriteString(l + "\n")
+		}
+		sb.WriteString(">>>>>>> " + nameRemote + "\n")
+		ours, theirs = nil, nil
+	}
+
+	for _, l := range Lines(local, remote) {
+		switch l.Kind {
+		case Equal:
+			flush()
+			sb.WriteString(l.Text + "\n")
+		case Delete:
+			ours = append(ours, l.Text)
+		case Insert:
+			theirs = append(theirs, l.Text)
+		}
+	}
+	flush()
+
+	return sb.String()
+}
+
+// HasMarkers reports whether a merge buffer still contains conflict markers
+func HasMarkers(text string) bool {
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, "<<<<<<< ") || line == "=======" || strings.HasPrefix(line, ">>>>>>> ") {
+			return true
+		}
+	}
+	return false
+}
+
+type hunk struct {
+	startA, countA int
+	startB, countB int
+	lines          []Line
+}
+
+// hunks groups changed lines with surrounding context
+func hunks(lines []Line, context int) []hunk {
+	var result []hunk
+
+	// Line numbers (1-based) in a and b before each diff line
+	posA := make([]int, len(lines)+1)
+	posB := make([]int, len(lines)+1)
+	a, b := 1, 1
+	for i, l := range lines {
+		posA[i], posB[i] = a, b
+		if l.Kind != Insert {
+			a++
+		}
+		if l.Kind != Delete {
+			b++
+		}
+	}
+	posA[len(lines)], posB[len(lines)] = a, b
+
+	i := 0
+	for i < len(lines) {
+		// Find the next change
+		for i < len(lines) && lines[i].Kind == Equal {
+			i++
+		}
+		if i == len(lines) {
+			break
+		}
+
+		start := max(i-context, 0)
+		end := i
+		// Extend while changes are within 2*context of each other
+		for end < len(lines) {
+			if lines[end].Kind != Equal {
+				end++
+				continue
+			}
+			run := end
+			for run < len(lines) && lines[run].Kind == Equal {
+				run++
+			}
+			if run == len(lines) || run-end > 2*context {
+				end = min(end+context, len(lines))
+				break
+			}
+			end = run
+		}
+
+		h := hunk{
+			startA: posA[start],
+			startB: posB[start],
+			lines:  lines[start:end],
+		}
+		for _, l := range h.lines {
+			if l.Kind != Insert {
+				h.countA++
+			}
+			if l.Kind != Delete {
+				h.countB++
+			}
+		}
+		result = append(result, h)
+		i = end
+	}
+
+	return result
+}
+
+// splitLines splits text into lines without a trailing empty element
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,88 @@
+package hashing
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"strings"
+)
+
+// Algorithm names a content hash. It is written in front of every checksum
+// ("sha256:9f86d0...") so checksums made with different algorithms can
+// live side by side while old data is migrated.
+type Algorithm string
+
+const (
+	MD5    Algorithm = "md5"    // Legacy, written without a prefix by older clients
+	SHA256 Algorithm = "sha256" // Current default
+)
+
+// Default is the algorithm used for all new checksums
+const Default = SHA256
+
+// New returns a fresh hash.Hash for the algorithm
+func New(alg Algorithm) hash.Hash {
+	switch alg {
+	case MD5:
+		return md5.New()
+	default:
+		return sha256.New()
+	}
+}
+
+// Sum returns the tagged checksum of content using the default algorithm
+func Sum(content string) string {
+	return SumWith(Default, content)
+}
+
+// SumWith returns the tagged checksum of content using alg
+func SumWith(alg Algorithm, content string) string {
+	h := New(alg)
+	h.Write([]byte(content))
+	return string(alg) + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// SumReader returns the bare hex digest of everything read from r, for
+// content-addressed storage where the algorithm is implied
+func SumReader(alg Algorithm, r io.Reader) (string, int64, error) {
+	h := New(alg)
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return "", n, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// Parse splits a checksum into its algorithm and hex digest. Untagged
+// checksums come from older clients and the server's existing rows: 32 hex
+// characters are MD5, 64 are SHA-256. Unknown input returns an empty algorithm.
+func Parse(checksum string) (Algorithm, string) {
+	if alg, digest, ok := strings.Cut(checksum, ":"); ok {
+		switch Algorithm(alg) {
+		case MD5, SHA256:
+			return Algorithm(alg), digest
+		}
+		return "", ""
+	}
+
+	switch len(checksum) {
+	case 32:
+		return MD5, checksum
+	case 64:
+		return SHA256, checksum
+	}
+	return "", ""
+}
+
+// Matches reports whether checksum was computed from content, using
+// whichever algorithm the checksum was made with
+func Matches(checksum, content string) bool {
+	alg, digest := Parse(checksum)
+	if alg == "" {
+		return false
+	}
+	_, want := Parse(SumWith(alg, content))
+	return strings.EqualFold(digest, want)
+}
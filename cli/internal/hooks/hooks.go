@@ -0,0 +1,135 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Hook names, as used in the [hooks] table of config.toml
+const (
+	PrePush    = "pre_push"    // Before a note is pushed; a non-zero exit vetoes it
+	PostPull   = "post_pull"   // After a note from the server was written or deleted
+	OnConflict = "on_conflict" // After a conflict was recorded
+	OnCreate   = "on_create"   // After a new note was first pushed
+)
+
+// Timeout is how long a hook may run before it is killed. A pre_push hook
+// that times out vetoes the change.
+const Timeout = 30 * time.Second
+
+// ErrVetoed is returned by Run when a pre_push hook refuses a change
+var ErrVetoed = errors.New("vetoed by pre_push hook")
+
+// Note describes the note a hook runs for. It is written to the hook's
+// stdin as JSON and mirrored in NOTE_* environment variables.
+type Note struct {
+	Hook   string `json:"hook"`
+	Vault  string `json:"vault"`
+	Path   string `json:"path"` // Relative to the notes directory
+	File   string `json:"file"` // Absolute path
+	Title  string `json:"title"`
+	Action string `json:"action"` // create, update, delete or conflict
+}
+
+// Runner runs the hooks configured for one vault
+type Runner struct {
+	commands map[string]string
+	dir      string
+	log      *slog.Logger
+}
+
+// New returns a runner for commands keyed by hook name. Hooks run through
+// the shell in dir, the notes directory.
+func New(commands map[string]string, dir string, log *slog.Logger) *Runner {
+	if log == nil {
+		log = slog.Default()
+	}
+	return &Runner{commands: commands, dir: dir, log: log}
+}
+
+// Has reports whether a command is configured for hook
+func (r *Runner) Has(hook string) bool {
+	return r != nil && r.commands[hook] != ""
+}
+
+// Run runs hook for n, if configured. Its output is logged, never shown,
+// so hooks can't draw over the TUI. Failures are logged and returned; for
+// pre_push they wrap ErrVetoed.
+func (r *Runner) Run(ctx context.Context, hook string, n Note) error {
+	if !r.Has(hook) {
+		return nil
+	}
+	command := r.commands[hook]
+	n.Hook = hook
+
+	input, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = r.dir
+	cmd.Env = append(os.Environ(),
+		"NOTES_HOOK="+hook,
+		"NOTES_VAULT="+n.Vault,
+		"NOTE_PATH="+n.Path,
+		"NOTE_FILE="+n.File,
+		"NOTE_TITLE="+n.Title,
+		"NOTE_ACTION="+n.Action,
+	)
+	cmd.Stdin = bytes.NewReader(append(input, '\n'))
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// 🔵 GO CONCEPT: WaitDelay
+	// A killed shell may leave children holding the output pipe open;
+	// WaitDelay stops Wait from blocking on them forever.
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err = cmd.Run()
+	attrs := []any{"hook", hook, "path", n.Path, "took", time.Since(start).Round(time.Millisecond)}
+	if out := strings.TrimSpace(output.String()); out != "" {
+		attrs = append(attrs, "output", out)
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", Timeout)
+	}
+	if err == nil {
+		r.log.Debug("hook ran", attrs...)
+		return nil
+	}
+
+	if hook == PrePush {
+		r.log.Info("push vetoed by hook", append(attrs, "err", err)...)
+		if reason := firstLine(output.String()); reason != "" {
+			return fmt.Errorf("%w: %s", ErrVetoed, reason)
+		}
+		return fmt.Errorf("%w (%v)", ErrVetoed, err)
+	}
+	r.log.Warn("hook failed", append(attrs, "err", err)...)
+	return fmt.Errorf("%s hook: %w", hook, err)
+}
+
+// firstLine returns the first non-empty line of s, the hook's reason for
+// a veto
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
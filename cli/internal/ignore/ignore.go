@@ -0,0 +1,90 @@
+package ignore
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Matcher decides which vault paths are kept out of sync. Patterns use
+// path.Match syntax against slash-separated paths relative to the vault:
+//
+//	*.tmp.md     no slash: matches the file name at any depth
+//	drafts/      trailing slash: everything under that directory
+//	drafts/**    same as drafts/
+//	journal/*.md with a slash: matches the whole relative path
+//
+// A nil *Matcher ignores nothing.
+type Matcher struct {
+	patterns []string
+}
+
+// New validates the patterns and returns a matcher for them
+func New(patterns []string) (*Matcher, error) {
+	m := &Matcher{}
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		p = strings.TrimPrefix(p, "/")
+		if _, err := path.Match(strings.TrimSuffix(strings.TrimSuffix(p, "**"), "/"), ""); err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern %q: %w", p, err)
+		}
+		m.patterns = append(m.patterns, p)
+	}
+	return m, nil
+}
+
+// Match reports whether rel (relative to the vault root) is ignored
+func (m *Matcher) Match(rel string) bool {
+	if m == nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	for _, p := range m.patterns {
+		switch {
+		case strings.HasSuffix(p, "/**") || strings.HasSuffix(p, "/"):
+			dir := strings.TrimSuffix(strings.TrimSuffix(p, "**"), "/")
+			if underDir(dir, rel) {
+				return true
+			}
+
+		case !strings.Contains(p, "/"):
+			if ok, _ := path.Match(p, path.Base(rel)); ok {
+				return true
+			}
+
+		default:
+			if ok, _ := path.Match(p, rel); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// underDir reports whether rel lies inside a directory matching dir. A dir
+// without a slash matches a directory of that name at any depth.
+func underDir(dir, rel string) bool {
+	parts := strings.Split(rel, "/")
+	parts = parts[:len(parts)-1] // Only directories
+
+	if !strings.Contains(dir, "/") {
+		for _, part := range parts {
+			if ok, _ := path.Match(dir, part); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	depth := strings.Count(dir, "/") + 1
+	if len(parts) < depth {
+		return false
+	}
+	ok, _ := path.Match(dir, strings.Join(parts[:depth], "/"))
+	return ok
+}
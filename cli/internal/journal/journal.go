@@ -0,0 +1,207 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daphen/notes-cli/internal/state"
+)
+
+// Rotation limits: the live file is rotated once it passes MaxBytes and
+// Backups older files are kept (journal.jsonl.1 is the most recent)
+const (
+	MaxBytes = 1 << 20
+	Backups  = 3
+)
+
+// Direction says which way a change travelled
+type Direction string
+
+const (
+	Push Direction = "push"
+	Pull Direction = "pull"
+)
+
+// Result is the outcome of syncing one path
+type Result string
+
+const (
+	OK       Result = "ok"
+	Conflict Result = "conflict"
+	Skipped  Result = "skipped"
+	Failed   Result = "error"
+)
+
+// Entry is one line of the sync journal
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Direction Direction `json:"direction"`
+	Action    string    `json:"action,omitempty"` // update, delete, attachment, resolve
+	Path      string    `json:"path"`
+	Result    Result    `json:"result"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Matches reports whether every word of query appears in the entry,
+// case-insensitively, e.g. "pull error" or "todo.md"
+func (e Entry) Matches(query string) bool {
+	text := strings.ToLower(strings.Join([]string{
+		string(e.Direction), e.Action, string(e.Result), e.Path, e.Error,
+	}, " "))
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// Journal appends sync results to a rotating JSON Lines file. It is safe
+// for concurrent use; a nil *Journal discards everything.
+type Journal struct {
+	path string
+	mu   sync.Mutex
+}
+
+// DefaultPath returns the journal file for a notes directory, next to its
+// sync state
+func DefaultPath(notesDir string) (string, error) {
+	return state.VaultFile(notesDir, "journal", ".jsonl")
+}
+
+// Open returns a journal writing to path. The file is created on the
+// first write.
+func Open(path string) *Journal {
+	return &Journal{path: path}
+}
+
+// Path returns the file the journal writes to
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// Record appends an entry, stamping it with the current time if unset
+func (j *Journal) Record(e Entry) error {
+	if j == nil {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(j.path), 0700); err != nil {
+		return err
+	}
+	if err := j.rotate(); err != nil {
+		return fmt.Errorf("failed to rotate journal: %w", err)
+	}
+
+	f, err := os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Log records the outcome of syncing path along with err's message, if any.
+// Write failures are ignored.
+func (j *Journal) Log(dir Direction, action, path string, result Result, err error) {
+	e := Entry{Direction: dir, Action: action, Path: path, Result: result}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	// Journaling must never break a sync
+	_ = j.Record(e)
+}
+
+// rotate shifts journal.jsonl -> .1 -> .2 ... once the live file is full
+func (j *Journal) rotate() error {
+	info, err := os.Stat(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() < MaxBytes {
+		return nil
+	}
+
+	for i := Backups - 1; i >= 1; i-- {
+		err := os.Rename(backupPath(j.path, i), backupPath(j.path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(j.path, backupPath(j.path, 1))
+}
+
+func backupPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// Read returns all entries, oldest first, including rotated files.
+// Lines that fail to parse (e.g. a torn write) are skipped.
+func (j *Journal) Read() ([]Entry, error) {
+	if j == nil {
+		return nil, nil
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var entries []Entry
+	for i := Backups; i >= 0; i-- {
+		path := j.path
+		if i > 0 {
+			path = backupPath(j.path, i)
+		}
+
+		fileEntries, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fileEntries...)
+	}
+	return entries, nil
+}
+
+func readFile(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,94 @@
+package keyring
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Secrets live in the freedesktop Secret Service (GNOME Keyring, KeePassXC,
+// KWallet) and are reached through libsecret's secret-tool, which talks
+// D-Bus for us. They are stored with the attributes
+//
+//	service=notes-cli vault=<vault name>
+//
+// NOTES_SECRET_TOOL overrides the secret-tool binary, e.g. to point at a
+// stand-in script in tests or on machines without a session bus.
+
+// Service is the service attribute every secret is stored under
+const Service = "notes-cli"
+
+// ErrNotFound means the keyring has no secret for the vault
+var ErrNotFound = errors.New("no password stored in the keyring")
+
+// ErrUnavailable means secret-tool isn't installed
+var ErrUnavailable = errors.New("secret service not available (install libsecret-tools for secret-tool)")
+
+func tool() (string, error) {
+	name := os.Getenv("NOTES_SECRET_TOOL")
+	if name == "" {
+		name = "secret-tool"
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", ErrUnavailable
+	}
+	return path, nil
+}
+
+// Available reports whether secrets can be stored
+func Available() bool {
+	_, err := tool()
+	return err == nil
+}
+
+// Lookup returns the password stored for a vault
+func Lookup(vault string) (string, error) {
+	path, err := tool()
+	if err != nil {
+		return "", err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, "lookup", "service", Service, "vault", vault)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		// secret-tool exits 1 without output when nothing matches
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && strings.TrimSpace(stderr.String()) == "" {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("keyring lookup failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	secret := strings.TrimRight(stdout.String(), "\r\n")
+	if secret == "" {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+// Store saves the password for a vault, replacing any previous one
+func Store(vault, secret string) error {
+	path, err := tool()
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(path, "store", "--label", fmt.Sprintf("notes-cli password (%s)", vault),
+		"service", Service, "vault", vault)
+	// The secret goes over stdin so it never shows up in ps
+	cmd.Stdin = strings.NewReader(secret)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("keyring store failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
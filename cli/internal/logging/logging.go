@@ -0,0 +1,186 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/daphen/notes-cli/internal/state"
+)
+
+// Options configure the logger, see the log_* config keys
+type Options struct {
+	Level      string // debug, info, warn or error
+	Format     string // text or json
+	File       string // "" = DefaultPath(), "-" = stderr
+	MaxSize    int    // Rotate the file after this many MB
+	MaxBackups int    // Rotated files kept (notes-cli.log.1 is the newest)
+}
+
+// Stderr as File logs to standard error instead of a file, e.g. under
+// systemd where the journal collects it
+const Stderr = "-"
+
+// Discard drops everything. It's the logger until New replaces it.
+var Discard = slog.New(slog.DiscardHandler)
+
+// DefaultPath returns the log file used when log_file isn't set. It is a
+// file rather than stderr so logging never draws over the TUI.
+func DefaultPath() (string, error) {
+	dir, err := state.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "notes-cli.log"), nil
+}
+
+// ParseLevel parses a log_level value
+func ParseLevel(s string) (slog.Level, error) {
+	// 🔵 GO CONCEPT: encoding.TextUnmarshaler
+	// slog.Level knows how to parse its own names ("debug", "WARN", ...)
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown level %q (debug, info, warn, error)", s)
+	}
+	return level, nil
+}
+
+// CheckFormat validates a log_format value
+func CheckFormat(s string) error {
+	if s != "text" && s != "json" {
+		return fmt.Errorf("unknown format %q (text, json)", s)
+	}
+	return nil
+}
+
+// New returns a logger writing to the configured destination. Close the
+// returned io.Closer on exit.
+func New(opts Options) (*slog.Logger, io.Closer, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := CheckFormat(opts.Format); err != nil {
+		return nil, nil, err
+	}
+
+	var out io.WriteCloser = nopCloser{os.Stderr}
+	if opts.File != Stderr {
+		path := opts.File
+		if path == "" {
+			if path, err = DefaultPath(); err != nil {
+				return nil, nil, err
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, nil, err
+		}
+		out = &rotatingFile{
+			path:       path,
+			maxBytes:   int64(opts.MaxSize) << 20,
+			maxBackups: opts.MaxBackups,
+		}
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	if opts.Format == "json" {
+		h = slog.NewJSONHandler(out, handlerOpts)
+	} else {
+		h = slog.NewTextHandler(out, handlerOpts)
+	}
+	return slog.New(h), out, nil
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// rotatingFile appends to path, shifting it to path.1, path.2 ... once it
+// passes maxBytes. It is safe for concurrent use.
+type rotatingFile struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.maxBytes > 0 && r.size+int64(len(p)) > r.maxBytes && r.size > 0 {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+// rotate shifts notes-cli.log -> .1 -> .2 ... and starts a new file
+func (r *rotatingFile) rotate() error {
+	r.f.Close()
+	r.f = nil
+
+	if r.maxBackups < 1 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return r.open()
+	}
+
+	for i := r.maxBackups - 1; i >= 1; i-- {
+		err := os.Rename(backupPath(r.path, i), backupPath(r.path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(r.path, backupPath(r.path, 1)); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+func backupPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
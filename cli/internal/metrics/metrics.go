@@ -0,0 +1,291 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A small implementation of the Prometheus text format, enough for
+// counters, gauges and histograms with labels.
+// https://prometheus.io/docs/instrumenting/exposition_formats/
+
+// metric is one family written by Registry.Write
+type metric interface {
+	write(w io.Writer) error
+}
+
+// Registry holds the metrics served together
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes every metric in the text format
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	for _, m := range metrics {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves the registry, e.g. at /metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// family is what counters, gauges and histograms share: a name, help text
+// and a value per combination of label values
+type family[T any] struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*T
+	keys   map[string][]string // Series key -> label values
+}
+
+func newFamily[T any](name, help, kind string, labels []string) *family[T] {
+	return &family[T]{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		series: make(map[string]*T),
+		keys:   make(map[string][]string),
+	}
+}
+
+// get returns the series for the label values, creating it with init.
+// Call with mu held.
+func (f *family[T]) get(values []string, init func() *T) *T {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s wants %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = init()
+		f.series[key] = s
+		f.keys[key] = append([]string(nil), values...)
+	}
+	return s
+}
+
+// each calls fn for every series, sorted by label values so the output is
+// stable. Call with mu held.
+func (f *family[T]) each(fn func(values []string, s *T) error) error {
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := fn(f.keys[k], f.series[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *family[T]) header(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.kind)
+	return err
+}
+
+// labelString renders {a="x",b="y"}, with extra pairs (e.g. le) appended
+func labelString(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var parts []string
+	for i, n := range names {
+		parts = append(parts, n+`="`+escapeLabel(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		parts = append(parts, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Counter only goes up, e.g. pushes
+type Counter struct {
+	f *family[float64]
+}
+
+// NewCounter registers a counter with the given label names
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{f: newFamily[float64](name, help, "counter", labels)}
+	r.register(c)
+	return c
+}
+
+// Inc adds 1 to the series for the label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v, which must not be negative
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic("metrics: counter " + c.f.name + " decreased")
+	}
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	*c.f.get(values, newFloat) += v
+}
+
+func (c *Counter) write(w io.Writer) error {
+	return writeValues(w, c.f)
+}
+
+// Gauge goes up and down, e.g. queue depth
+type Gauge struct {
+	f *family[float64]
+}
+
+// NewGauge registers a gauge with the given label names
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{f: newFamily[float64](name, help, "gauge", labels)}
+	r.register(g)
+	return g
+}
+
+// Set sets the series for the label values
+func (g *Gauge) Set(v float64, values ...string) {
+	g.f.mu.Lock()
+	defer g.f.mu.Unlock()
+	*g.f.get(values, newFloat) = v
+}
+
+// Add adds v, which may be negative
+func (g *Gauge) Add(v float64, values ...string) {
+	g.f.mu.Lock()
+	defer g.f.mu.Unlock()
+	*g.f.get(values, newFloat) += v
+}
+
+func (g *Gauge) write(w io.Writer) error {
+	return writeValues(w, g.f)
+}
+
+func writeValues(w io.Writer, f *family[float64]) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.header(w); err != nil {
+		return err
+	}
+	return f.each(func(values []string, v *float64) error {
+		_, err := fmt.Fprintf(w, "%s%s %s\n", f.name, labelString(f.labels, values), formatFloat(*v))
+		return err
+	})
+}
+
+// Histogram counts observations into buckets, e.g. request durations
+type Histogram struct {
+	f       *family[histogramSeries]
+	buckets []float64 // Upper bounds, ascending, without +Inf
+}
+
+type histogramSeries struct {
+	counts []uint64 // Per bucket, not cumulative; the last is +Inf
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds
+// and label names
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		f:       newFamily[histogramSeries](name, help, "histogram", labels),
+		buckets: append([]float64(nil), buckets...),
+	}
+	sort.Float64s(h.buckets)
+	r.register(h)
+	return h
+}
+
+// Observe records one value in the series for the label values
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+
+	s := h.f.get(values, func() *histogramSeries {
+		return &histogramSeries{counts: make([]uint64, len(h.buckets)+1)}
+	})
+	// 🔵 GO CONCEPT: sort.SearchFloat64s
+	// Binary search for the first bucket whose bound is >= v; past the
+	// end means the +Inf bucket.
+	s.counts[sort.SearchFloat64s(h.buckets, v)]++
+	s.sum += v
+	s.count++
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+
+	if err := h.f.header(w); err != nil {
+		return err
+	}
+	return h.f.each(func(values []string, s *histogramSeries) error {
+		var cumulative uint64
+		for i, n := range s.counts {
+			cumulative += n
+			le := "+Inf"
+			if i < len(h.buckets) {
+				le = formatFloat(h.buckets[i])
+			}
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.f.name, labelString(h.f.labels, values, "le", le), cumulative); err != nil {
+				return err
+			}
+		}
+		_, err := fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n",
+			h.f.name, labelString(h.f.labels, values), formatFloat(s.sum),
+			h.f.name, labelString(h.f.labels, values), s.count)
+		return err
+	})
+}
+
+func newFloat() *float64 { return new(float64) }
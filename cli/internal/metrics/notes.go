@@ -0,0 +1,44 @@
+package metrics
+
+import "time"
+
+// Default holds notes-cli's metrics, served by -watch and the daemon when
+// metrics_addr is set
+var Default = &Registry{}
+
+// The metrics themselves. Labels are listed in the help text's order.
+var (
+	Syncs = Default.NewCounter("notes_sync_total",
+		"Notes synced, by vault, direction (push, pull) and result (ok, conflict, skipped, error).",
+		"vault", "direction", "result")
+
+	Bytes = Default.NewCounter("notes_sync_bytes_total",
+		"Note content bytes sent or written, by vault and direction.",
+		"vault", "direction")
+
+	Failures = Default.NewCounter("notes_sync_failures_total",
+		"Failed syncs, by vault, direction and type (network, auth, rate_limited, server, client, local).",
+		"vault", "direction", "type")
+
+	QueueDepth = Default.NewGauge("notes_queue_depth",
+		"Local changes waiting to be pushed, by vault.",
+		"vault")
+
+	LastSuccess = Default.NewGauge("notes_last_success_timestamp_seconds",
+		"Unix time of the last successful push or pull, by vault.",
+		"vault")
+
+	RequestDuration = Default.NewHistogram("notes_http_request_duration_seconds",
+		"Server request latency, by method, path and status code (0 = no response).",
+		[]float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		"method", "path", "code")
+
+	BuildInfo = Default.NewGauge("notes_build_info",
+		"Always 1, labelled with the notes-cli version.",
+		"version")
+)
+
+// MarkSuccess records that a vault just synced successfully
+func MarkSuccess(vault string) {
+	LastSuccess.Set(float64(time.Now().Unix()), vault)
+}
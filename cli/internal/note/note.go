@@ -1,12 +1,14 @@
 package note
 
 import (
-	"crypto/md5"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/daphen/notes-cli/internal/hashing"
+	"github.com/daphen/notes-cli/internal/seal"
 )
 
 // Note represents a processed note with all metadata
@@ -21,8 +23,14 @@ type Note struct {
 // ExtractTitle extracts the title from markdown content
 // It looks for the first # heading, otherwise uses the filename
 func ExtractTitle(content, path string) string {
+	// A title in frontmatter wins over headings
+	fm, body := SplitFrontmatter(content)
+	if title := strings.TrimSpace(fm["title"]); title != "" {
+		return LimitTitle(title)
+	}
+
 	// Try to extract from first line if it's a heading
-	lines := strings.Split(content, "\n")
+	lines := strings.Split(body, "\n")
 	for _, line := range lines {
 		trimmed := strings.TrimSpace(line)
 		if strings.HasPrefix(trimmed, "#") {
@@ -72,10 +80,9 @@ func LimitTitle(title string) string {
 	return title
 }
 
-// CalculateChecksum calculates MD5 checksum of content
+// CalculateChecksum returns the algorithm-tagged checksum of content
 func CalculateChecksum(content string) string {
-	hash := md5.Sum([]byte(content))
-	return hex.EncodeToString(hash[:])
+	return hashing.Sum(content)
 }
 
 // GenerateFilename generates a timestamp-based filename
@@ -101,3 +108,105 @@ func ProcessNote(path, content, action string) Note {
 		Action:   action,
 	}
 }
+
+// ErrUnsealed is returned for notes marked encrypted whose body is still
+// plaintext - they must be locked in the TUI before they can be synced.
+var ErrUnsealed = errors.New("note is marked encrypted but has not been locked yet")
+
+// SplitFrontmatter separates a leading "---" delimited block of simple
+// "key: value" lines from the body. Notes without frontmatter return an
+// empty map and the content unchanged. Lines may end in CRLF; the body is
+// always a suffix of content.
+func SplitFrontmatter(content string) (map[string]string, string) {
+	fm := make(map[string]string)
+
+	var rest string
+	switch {
+	case strings.HasPrefix(content, "---\n"):
+		rest = content[len("---\n"):]
+	case strings.HasPrefix(content, "---\r\n"):
+		rest = content[len("---\r\n"):]
+	default:
+		return fm, content
+	}
+
+	end := strings.Index(rest, "\n---")
+	if end == -1 {
+		return fm, content
+	}
+
+	for _, line := range strings.Split(rest[:end], "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		fm[strings.TrimSpace(key)] = value
+	}
+
+	body := rest[end+len("\n---"):]
+	if strings.HasPrefix(body, "\r\n") {
+		body = body[len("\r\n"):]
+	} else {
+		body = strings.TrimPrefix(body, "\n")
+	}
+	return fm, body
+}
+
+// frontmatterBlock returns the raw frontmatter (including delimiters) so it
+// can be preserved verbatim around a sealed body
+func frontmatterBlock(content string) string {
+	_, body := SplitFrontmatter(content)
+	return content[:len(content)-len(body)]
+}
+
+// IsEncrypted reports whether the note is marked `encrypted: true`
+func IsEncrypted(content string) bool {
+	fm, _ := SplitFrontmatter(content)
+	return strings.EqualFold(fm["encrypted"], "true")
+}
+
+// IsSealed reports whether the note body is an encrypted block
+func IsSealed(content string) bool {
+	_, body := SplitFrontmatter(content)
+	return seal.IsArmored(body)
+}
+
+// CheckSyncable returns ErrUnsealed for encrypted notes that would
+// otherwise leak their plaintext to the server
+func CheckSyncable(content string) error {
+	if IsEncrypted(content) && !IsSealed(content) {
+		return ErrUnsealed
+	}
+	return nil
+}
+
+// Lock encrypts the note body, keeping the frontmatter readable
+func Lock(content, passphrase string) (string, error) {
+	if IsSealed(content) {
+		return content, nil
+	}
+
+	_, body := SplitFrontmatter(content)
+	sealed, err := seal.Seal(body, passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	return frontmatterBlock(content) + sealed, nil
+}
+
+// Unlock decrypts the note body. Notes that aren't sealed are returned as-is.
+func Unlock(content, passphrase string) (string, error) {
+	if !IsSealed(content) {
+		return content, nil
+	}
+
+	_, body := SplitFrontmatter(content)
+	plain, err := seal.Open(body, passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	return frontmatterBlock(content) + plain, nil
+}
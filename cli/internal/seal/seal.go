@@ -0,0 +1,158 @@
+package seal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Armor markers wrap the ciphertext so a sealed note is still a readable
+// text file, both on disk and in the server's content column.
+const (
+	BeginMarker = "-----BEGIN NOTES ENCRYPTED NOTE-----"
+	EndMarker   = "-----END NOTES ENCRYPTED NOTE-----"
+)
+
+const (
+	version    = "1"
+	kdfName    = "pbkdf2-sha256"
+	iterations = 600000
+	saltSize   = 16
+	keySize    = 32 // AES-256
+	lineWidth  = 64
+)
+
+// ErrWrongPassphrase is returned when the ciphertext can't be authenticated,
+// which almost always means the passphrase was wrong.
+var ErrWrongPassphrase = errors.New("wrong passphrase or corrupted note")
+
+// IsArmored reports whether text starts with a sealed block
+func IsArmored(text string) bool {
+	return strings.HasPrefix(strings.TrimSpace(text), BeginMarker)
+}
+
+// Seal encrypts plaintext with a key derived from passphrase and returns
+// an armored block
+func Seal(plaintext, passphrase string) (string, error) {
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase is required")
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	gcm, err := newGCM(passphrase, salt, iterations)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	// salt || nonce || ciphertext+tag
+	payload := append(salt, nonce...)
+	payload = gcm.Seal(payload, nonce, []byte(plaintext), nil)
+
+	var b strings.Builder
+	b.WriteString(BeginMarker + "\n")
+	b.WriteString("Version: " + version + "\n")
+	b.WriteString("KDF: " + kdfName + "\n")
+	b.WriteString("Iterations: " + strconv.Itoa(iterations) + "\n\n")
+
+	encoded := base64.StdEncoding.EncodeToString(payload)
+	for len(encoded) > lineWidth {
+		b.WriteString(encoded[:lineWidth] + "\n")
+		encoded = encoded[lineWidth:]
+	}
+	b.WriteString(encoded + "\n")
+	b.WriteString(EndMarker + "\n")
+
+	return b.String(), nil
+}
+
+// Open decrypts an armored block produced by Seal
+func Open(armored, passphrase string) (string, error) {
+	text := strings.TrimSpace(armored)
+	if !strings.HasPrefix(text, BeginMarker) || !strings.HasSuffix(text, EndMarker) {
+		return "", fmt.Errorf("not an encrypted note")
+	}
+	text = strings.TrimSuffix(strings.TrimPrefix(text, BeginMarker), EndMarker)
+
+	// Headers end at the first blank line, the base64 body follows
+	headerPart, bodyPart, found := strings.Cut(strings.TrimLeft(text, "\n"), "\n\n")
+	if !found {
+		return "", fmt.Errorf("malformed encrypted note: missing header separator")
+	}
+
+	headers := make(map[string]string)
+	for _, line := range strings.Split(headerPart, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if ok {
+			headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+
+	if headers["Version"] != version {
+		return "", fmt.Errorf("unsupported encrypted note version %q", headers["Version"])
+	}
+	if headers["KDF"] != kdfName {
+		return "", fmt.Errorf("unsupported key derivation %q", headers["KDF"])
+	}
+	iter, err := strconv.Atoi(headers["Iterations"])
+	if err != nil || iter <= 0 {
+		return "", fmt.Errorf("malformed encrypted note: bad iteration count")
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(bodyPart), ""))
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted note: %w", err)
+	}
+	if len(payload) < saltSize {
+		return "", fmt.Errorf("malformed encrypted note: payload too short")
+	}
+
+	salt := payload[:saltSize]
+	gcm, err := newGCM(passphrase, salt, iter)
+	if err != nil {
+		return "", err
+	}
+
+	rest := payload[saltSize:]
+	if len(rest) < gcm.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted note: payload too short")
+	}
+	nonce, ciphertext := rest[:gcm.NonceSize()], rest[gcm.NonceSize():]
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrWrongPassphrase
+	}
+
+	return string(plaintext), nil
+}
+
+// newGCM derives the AES key and wraps it in an AEAD
+func newGCM(passphrase string, salt []byte, iter int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iter, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,203 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// AllowMarker on a line stops secrets starting on it from being reported,
+// for example keys and other false positives
+const AllowMarker = "notes-cli:allow-secret"
+
+// ErrFound is returned when a note about to be pushed contains what looks
+// like a secret
+var ErrFound = errors.New("note contains a possible secret")
+
+// Finding is one possible secret in a note
+type Finding struct {
+	Rule string // e.g. "aws-access-key"
+	Line int    // 1-based line the match starts on
+
+	start, end int // Byte offsets of the match
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s on line %d", descriptions[f.Rule], f.Line)
+}
+
+// rule is a pattern for one kind of secret. With a capture group, only
+// the group is the secret (and redacted).
+type rule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+var rules = []rule{
+	// Without an END line the key runs to the first blank line
+	{"private-key", regexp.MustCompile(`(?s)-----BEGIN [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----(?:.*?-----END [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----|[^\n]*(?:\n[^\n]+)*)`)},
+	{"aws-access-key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"aws-secret-key", regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+]{40})\b`)},
+	{"github-token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`)},
+}
+
+var descriptions = map[string]string{
+	"private-key":    "private key",
+	"aws-access-key": "AWS access key",
+	"aws-secret-key": "AWS secret key",
+	"github-token":   "GitHub token",
+	"high-entropy":   "random-looking string",
+}
+
+// Candidates for the entropy check: long runs of base64/token characters.
+// URLs are left out, their paths are often long random IDs.
+var (
+	tokenRe = regexp.MustCompile(`[A-Za-z0-9+/=_\-]{24,}`)
+	urlRe   = regexp.MustCompile(`\b(?:[a-zA-Z][a-zA-Z0-9+.\-]*://|data:)\S+`)
+)
+
+// A token looks random rather than like words, paths or identifiers with
+// at least minEntropy bits per character (Shannon entropy) and when at
+// least minSwitches of its adjacent letters and digits differ in kind
+// (upper, lower, digit). Words and CamelCase change kind only between
+// words; generated keys about every other character.
+const (
+	minEntropy  = 4.0
+	minSwitches = 0.45
+)
+
+// Scan returns the possible secrets in content, in order
+func Scan(content string) []Finding {
+	var found []Finding
+	taken := func(start, end int) bool {
+		for _, f := range found {
+			if start < f.end && end > f.start {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, r := range rules {
+		for _, m := range r.re.FindAllStringSubmatchIndex(content, -1) {
+			start, end := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+			if !taken(start, end) {
+				found = append(found, Finding{Rule: r.name, start: start, end: end})
+			}
+		}
+	}
+
+	urls := urlRe.FindAllStringIndex(content, -1)
+	for _, m := range tokenRe.FindAllStringIndex(content, -1) {
+		if taken(m[0], m[1]) || inAny(urls, m[0], m[1]) {
+			continue
+		}
+		if looksRandom(content[m[0]:m[1]]) {
+			found = append(found, Finding{Rule: "high-entropy", start: m[0], end: m[1]})
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].start < found[j].start })
+
+	// Drop allowlisted lines and number the rest
+	lines := strings.Split(content, "\n")
+	kept := found[:0]
+	for _, f := range found {
+		line := strings.Count(content[:f.start], "\n")
+		if strings.Contains(lines[line], AllowMarker) {
+			continue
+		}
+		f.Line = line + 1
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// Redact replaces each finding in content with a placeholder naming the
+// kind of secret
+func Redact(content string, found []Finding) string {
+	var b strings.Builder
+	last := 0
+	for _, f := range found {
+		b.WriteString(content[last:f.start])
+		b.WriteString("[REDACTED " + f.Rule + "]")
+		last = f.end
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+// looksRandom reports whether token mixes upper and lower case letters and
+// digits the way generated keys do (see minEntropy)
+func looksRandom(token string) bool {
+	seen := make(map[int]bool)
+	alnum, switches, prev := 0, 0, -1
+	for _, r := range token {
+		k := kind(r)
+		if k == other {
+			continue
+		}
+		if prev != -1 && k != prev {
+			switches++
+		}
+		seen[k] = true
+		alnum++
+		prev = k
+	}
+	if !seen[upper] || !seen[lower] || !seen[digit] {
+		return false
+	}
+	return entropy(token) >= minEntropy && float64(switches)/float64(alnum-1) >= minSwitches
+}
+
+// Kinds of characters for looksRandom
+const (
+	upper = iota
+	lower
+	digit
+	other
+)
+
+func kind(r rune) int {
+	switch {
+	case unicode.IsUpper(r):
+		return upper
+	case unicode.IsLower(r):
+		return lower
+	case unicode.IsDigit(r):
+		return digit
+	}
+	return other
+}
+
+// entropy is the Shannon entropy of s in bits per character
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	var h float64
+	n := float64(len(s))
+	for _, c := range counts {
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// inAny reports whether [start, end) lies within one of spans
+func inAny(spans [][]int, start, end int) bool {
+	for _, s := range spans {
+		if start >= s[0] && end <= s[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,280 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/daphen/notes-cli/internal/hashing"
+)
+
+// CurrentVersion is the state file format written by this build, so a
+// later format can tell which files to migrate
+const CurrentVersion = 1
+
+// Entry is what we know about a note at the moment it was last synced
+type Entry struct {
+	Checksum  string    `json:"checksum"`            // Tagged checksum of the synced content
+	UpdatedAt string    `json:"updatedAt,omitempty"` // Server timestamp of that version
+	SyncedAt  time.Time `json:"syncedAt"`
+}
+
+// Conflict holds the server's version of a note that was also edited
+// locally. The local version stays on disk untouched.
+type Conflict struct {
+	RemoteContent   string    `json:"remoteContent"`
+	RemoteUpdatedAt string    `json:"remoteUpdatedAt,omitempty"`
+	DetectedAt      time.Time `json:"detectedAt"`
+}
+
+// State remembers the last synced version of every note, so later runs can
+// tell local edits from remote ones. It is safe for concurrent use.
+type State struct {
+	Version int              `json:"version"`
+	Notes   map[string]Entry `json:"notes"`
+	Cursor  string           `json:"cursor,omitempty"` // Change feed resume point
+
+	// Notes changed on both sides, waiting to be resolved in the TUI
+	Conflicts map[string]Conflict `json:"conflicts,omitempty"`
+
+	// Content hash of every attachment as last synced, keyed by path
+	Attachments map[string]string `json:"attachments,omitempty"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// DefaultPath returns the state file for a notes directory. State lives
+// outside the notes directory so it's never picked up by the watcher or
+// synced to the server.
+func DefaultPath(notesDir string) (string, error) {
+	return VaultFile(notesDir, "state", ".json")
+}
+
+// VaultFile returns the path of a per-vault file named kind in the state
+// directory, e.g. state-<hash>.json. The hash of the notes directory keeps
+// vaults apart.
+func VaultFile(notesDir, kind, ext string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	abs, err := filepath.Abs(notesDir)
+	if err != nil {
+		return "", err
+	}
+
+	_, digest := hashing.Parse(hashing.Sum(abs))
+	return filepath.Join(dir, kind+"-"+digest[:16]+ext), nil
+}
+
+// Dir returns the notes-cli state directory, $XDG_STATE_HOME/notes-cli or
+// ~/.local/state/notes-cli
+func Dir() (string, error) {
+	base := os.Getenv("XDG_STATE_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(base, "notes-cli"), nil
+}
+
+// Load reads the state file. A missing file yields an empty state.
+func Load(path string) (*State, error) {
+	s := &State{
+		Version: CurrentVersion,
+		Notes:   make(map[string]Entry),
+		path:    path,
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse sync state %s: %w", path, err)
+	}
+	if s.Notes == nil {
+		s.Notes = make(map[string]Entry)
+	}
+
+	return s, nil
+}
+
+// Reload replaces the state with what is on disk, picking up changes saved
+// by another process
+func (s *State) Reload() error {
+	fresh, err := Load(s.path)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Version = fresh.Version
+	s.Notes = fresh.Notes
+	s.Cursor = fresh.Cursor
+	s.Conflicts = fresh.Conflicts
+	s.Attachments = fresh.Attachments
+	return nil
+}
+
+// Save writes the state atomically (temp file + rename)
+func (s *State) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Get returns the entry for a note
+func (s *State) Get(path string) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.Notes[path]
+	return e, ok
+}
+
+// Record remembers that content with checksum was synced for path
+func (s *State) Record(path, checksum, updatedAt string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Notes[path] = Entry{
+		Checksum:  checksum,
+		UpdatedAt: updatedAt,
+		SyncedAt:  time.Now(),
+	}
+}
+
+// Remove forgets a note (after it was deleted on both sides)
+func (s *State) Remove(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Notes, path)
+}
+
+// SetCursor remembers where the change feed should resume
+func (s *State) SetCursor(cursor string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Cursor = cursor
+}
+
+// GetCursor returns the change feed resume point ("" = from the beginning)
+func (s *State) GetCursor() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Cursor
+}
+
+// AddConflict records the remote side of a conflicting note
+func (s *State) AddConflict(path, remoteContent, remoteUpdatedAt string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Conflicts == nil {
+		s.Conflicts = make(map[string]Conflict)
+	}
+	s.Conflicts[path] = Conflict{
+		RemoteContent:   remoteContent,
+		RemoteUpdatedAt: remoteUpdatedAt,
+		DetectedAt:      time.Now(),
+	}
+}
+
+// GetConflict returns the recorded conflict for a note
+func (s *State) GetConflict(path string) (Conflict, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.Conflicts[path]
+	return c, ok
+}
+
+// ResolveConflict forgets a conflict once the user picked a version
+func (s *State) ResolveConflict(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Conflicts, path)
+}
+
+// ConflictPaths returns the paths of all unresolved conflicts, sorted
+func (s *State) ConflictPaths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	paths := make([]string, 0, len(s.Conflicts))
+	for p := range s.Conflicts {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// AttachmentHash returns the content hash of an attachment as last synced
+func (s *State) AttachmentHash(path string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.Attachments[path]
+	return h, ok
+}
+
+// RecordAttachment remembers that content with hash was synced for path
+func (s *State) RecordAttachment(path, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Attachments == nil {
+		s.Attachments = make(map[string]string)
+	}
+	s.Attachments[path] = hash
+}
+
+// RemoveAttachment forgets an attachment (after it was deleted on the server)
+func (s *State) RemoveAttachment(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Attachments, path)
+}
+
+// Paths returns all known note paths, sorted
+func (s *State) Paths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	paths := make([]string, 0, len(s.Notes))
+	for p := range s.Notes {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Open loads the state for a notes directory
+func Open(notesDir string) (*State, error) {
+	path, err := DefaultPath(notesDir)
+	if err != nil {
+		return nil, err
+	}
+	return Load(path)
+}
@@ -0,0 +1,250 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/daphen/notes-cli/internal/attachment"
+	"github.com/daphen/notes-cli/internal/client"
+)
+
+// AttachmentClient is the part of client.Client used for attachments
+type AttachmentClient interface {
+	MissingAttachments(ctx context.Context, hashes []string) ([]string, error)
+	UploadAttachment(ctx context.Context, hash string, body io.Reader, size int64, contentType string) error
+	DownloadAttachment(ctx context.Context, hash string, w io.Writer) error
+	PutAttachmentRefs(ctx context.Context, refs []client.AttachmentRef) error
+	DeleteAttachmentRefs(ctx context.Context, paths []string) error
+	ListAttachments(ctx context.Context) ([]client.AttachmentRef, error)
+}
+
+// AttachmentReport summarizes an attachment push or pull
+type AttachmentReport struct {
+	Uploaded   []string // Paths whose content was sent
+	Downloaded []string // Paths written locally
+	Skipped    int      // Content the other side already had
+	Bytes      int64    // Content bytes transferred
+
+	// Every path whose content now matches the server, for the sync state
+	Synced []client.AttachmentRef
+}
+
+// PushAttachments uploads attachment content the server doesn't have yet
+// and then records the path -> hash mapping for every attachment. Content
+// shared by several paths is uploaded once.
+func PushAttachments(ctx context.Context, c AttachmentClient, notesDir string, atts []attachment.Attachment) (*AttachmentReport, error) {
+	report := &AttachmentReport{}
+	if len(atts) == 0 {
+		return report, nil
+	}
+
+	hashes := make([]string, len(atts))
+	for i, att := range atts {
+		hashes[i] = att.Hash
+	}
+
+	missing, err := c.MissingAttachments(ctx, hashes)
+	if err != nil {
+		return report, err
+	}
+	needed := make(map[string]bool, len(missing))
+	for _, h := range missing {
+		needed[h] = true
+	}
+
+	var refs []client.AttachmentRef
+	for _, att := range atts {
+		for _, p := range att.Paths {
+			refs = append(refs, client.AttachmentRef{Path: p, Hash: att.Hash, Size: att.Size})
+		}
+
+		if !needed[att.Hash] {
+			report.Skipped++
+			continue
+		}
+
+		if err := uploadFile(ctx, c, notesDir, att); err != nil {
+			return report, err
+		}
+		report.Uploaded = append(report.Uploaded, att.Paths[0])
+		report.Bytes += att.Size
+	}
+
+	if err := c.PutAttachmentRefs(ctx, refs); err != nil {
+		return report, err
+	}
+	report.Synced = refs
+
+	return report, nil
+}
+
+// PushAttachmentFiles uploads attachments seen by the watcher, recording
+// all their refs in one request. synced returns the hash each path had when
+// it was last synced; files still matching it, e.g. ones just written by a
+// pull, are left out.
+func PushAttachmentFiles(ctx context.Context, c AttachmentClient, notesDir string, relPaths []string, synced func(path string) (string, bool)) (*AttachmentReport, error) {
+	byHash := make(map[string]int) // hash -> index in atts
+	var atts []attachment.Attachment
+	for _, relPath := range relPaths {
+		hash, size, err := attachment.HashFile(filepath.Join(notesDir, relPath))
+		if err != nil {
+			return &AttachmentReport{}, err
+		}
+
+		rel := filepath.ToSlash(relPath)
+		if last, ok := synced(rel); ok && last == hash {
+			continue
+		}
+		if i, ok := byHash[hash]; ok {
+			atts[i].Paths = append(atts[i].Paths, rel)
+			continue
+		}
+		byHash[hash] = len(atts)
+		atts = append(atts, attachment.Attachment{Hash: hash, Size: size, Paths: []string{rel}})
+	}
+
+	return PushAttachments(ctx, c, notesDir, atts)
+}
+
+func uploadFile(ctx context.Context, c AttachmentClient, notesDir string, att attachment.Attachment) error {
+	f, err := os.Open(filepath.Join(notesDir, filepath.FromSlash(att.Paths[0])))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Hashed moments ago; a file rewritten since would be sent with the
+	// wrong hash and length
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() != att.Size {
+		return fmt.Errorf("%s changed while uploading", att.Paths[0])
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(att.Paths[0]))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	if err := c.UploadAttachment(ctx, att.Hash, f, att.Size, contentType); err != nil {
+		return fmt.Errorf("%s: %w", att.Paths[0], err)
+	}
+	return nil
+}
+
+// PullAttachments downloads every attachment whose local copy is missing or
+// differs from the server. Each hash is fetched at most once; further paths
+// with the same content are copied from the first download.
+func PullAttachments(ctx context.Context, c AttachmentClient, notesDir string) (*AttachmentReport, error) {
+	report := &AttachmentReport{}
+
+	refs, err := c.ListAttachments(ctx)
+	if errors.Is(err, client.ErrNoAttachments) {
+		return report, nil // Nothing to pull from this server
+	}
+	if err != nil {
+		return report, err
+	}
+
+	fetched := make(map[string]string) // hash -> local full path
+	for _, ref := range refs {
+		fullPath, err := safeJoin(notesDir, ref.Path)
+		if err != nil {
+			return report, err
+		}
+
+		if hash, _, err := attachment.HashFile(fullPath); err == nil && hash == ref.Hash {
+			report.Skipped++
+			report.Synced = append(report.Synced, ref)
+			fetched[ref.Hash] = fullPath
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return report, fmt.Errorf("failed to create directory: %w", err)
+		}
+
+		if src, ok := fetched[ref.Hash]; ok {
+			if err := copyFile(src, fullPath); err != nil {
+				return report, err
+			}
+		} else {
+			if err := downloadFile(ctx, c, ref.Hash, fullPath); err != nil {
+				return report, fmt.Errorf("%s: %w", ref.Path, err)
+			}
+			fetched[ref.Hash] = fullPath
+			report.Bytes += ref.Size
+		}
+
+		report.Downloaded = append(report.Downloaded, ref.Path)
+		report.Synced = append(report.Synced, ref)
+	}
+
+	return report, nil
+}
+
+// downloadFile writes to a temp file first so an interrupted download never
+// leaves a truncated attachment behind
+func downloadFile(ctx context.Context, c AttachmentClient, hash, fullPath string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), ".download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := c.DownloadAttachment(ctx, hash, tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	got, _, err := attachment.HashFile(tmp.Name())
+	if err != nil {
+		return err
+	}
+	if got != hash {
+		return fmt.Errorf("downloaded content doesn't match hash %s", hash)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fullPath)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// safeJoin refuses server paths that would escape the notes directory
+func safeJoin(notesDir, rel string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(rel)) {
+		return "", fmt.Errorf("refusing attachment path outside notes directory: %s", rel)
+	}
+	return filepath.Join(notesDir, filepath.FromSlash(rel)), nil
+}
@@ -0,0 +1,217 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/daphen/notes-cli/internal/client"
+)
+
+// Pusher is the part of client.Client the pool needs
+type Pusher interface {
+	Push(ctx context.Context, notes []client.Note) (*client.SyncResponse, error)
+}
+
+// Options configures a parallel push
+type Options struct {
+	Workers           int     // Concurrent requests, minimum 1
+	RequestsPerSecond float64 // Shared request rate across workers, <= 0 = unlimited
+	BatchBytes        int64   // Max encoded size of one batch
+	MaxRetries        int     // Retries per batch after 429/503 responses
+
+	// Progress is called after every batch, from the worker goroutine
+	Progress func(BatchResult)
+}
+
+// BatchResult describes one finished batch
+type BatchResult struct {
+	Worker int
+	Notes  int
+	Bytes  int64 // Note content bytes in the batch
+	Err    error
+}
+
+// Failure records a note that couldn't be pushed
+type Failure struct {
+	Path string
+	Err  error
+}
+
+// Report aggregates the results of every batch
+type Report struct {
+	Accepted  []string
+	Conflicts []string
+	Failed    []Failure
+	Batches   int
+	Retries   int
+}
+
+// Pool pushes batches of notes concurrently
+type Pool struct {
+	pusher Pusher
+	opts   Options
+}
+
+// New creates a push pool
+func New(pusher Pusher, opts Options) *Pool {
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.BatchBytes <= 0 {
+		opts.BatchBytes = client.DefaultMaxBodyBytes
+	}
+	return &Pool{pusher: pusher, opts: opts}
+}
+
+// PushAll pushes notes using all workers and returns the combined report.
+//
+// Notes are sharded by path, and each shard is owned by exactly one worker
+// which sends its batches in order. Two changes to the same path can
+// therefore never race each other, while different paths go out in parallel.
+//
+// Once ctx is done no new batch is started; the notes left over are
+// reported as failed with ctx's error.
+func (p *Pool) PushAll(ctx context.Context, notes []client.Note) *Report {
+	shards := make([][]client.Note, p.opts.Workers)
+	for _, n := range notes {
+		i := shardFor(n.Path, len(shards))
+		shards[i] = append(shards[i], n)
+	}
+
+	limiter := newLimiter(p.opts.RequestsPerSecond)
+	defer limiter.stop()
+
+	report := &Report{}
+	var mu sync.Mutex
+
+	// 🔵 GO CONCEPT: sync.WaitGroup
+	// A WaitGroup counts running goroutines. Add before starting each one,
+	// Done when it finishes, and Wait blocks until the count is back to zero.
+	var wg sync.WaitGroup
+
+	for worker, shard := range shards {
+		if len(shard) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for _, batch := range client.SplitChunks(shard, p.opts.BatchBytes) {
+				resp, retries, err := p.pushBatch(ctx, batch, limiter)
+
+				mu.Lock()
+				report.Batches++
+				report.Retries += retries
+				if err != nil {
+					for _, n := range batch {
+						report.Failed = append(report.Failed, Failure{Path: n.Path, Err: err})
+					}
+				} else {
+					report.Accepted = append(report.Accepted, resp.Accepted...)
+					report.Conflicts = append(report.Conflicts, resp.Conflicts...)
+				}
+				mu.Unlock()
+
+				if p.opts.Progress != nil {
+					p.opts.Progress(BatchResult{
+						Worker: worker,
+						Notes:  len(batch),
+						Bytes:  batchBytes(batch),
+						Err:    err,
+					})
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	return report
+}
+
+// pushBatch sends one batch, backing off and retrying when the server
+// asks us to slow down
+func (p *Pool) pushBatch(ctx context.Context, batch []client.Note, limiter *limiter) (*client.SyncResponse, int, error) {
+	backoff := time.Second
+
+	for attempt := 0; ; attempt++ {
+		if err := limiter.wait(ctx); err != nil {
+			return nil, attempt, err
+		}
+
+		resp, err := p.pusher.Push(ctx, batch)
+		if err == nil {
+			return resp, attempt, nil
+		}
+
+		var statusErr *client.StatusError
+		if !errors.As(err, &statusErr) || !statusErr.Temporary() || attempt >= p.opts.MaxRetries {
+			return nil, attempt, err
+		}
+
+		delay := statusErr.RetryAfter
+		if delay == 0 {
+			delay = backoff
+			backoff *= 2
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, attempt, ctx.Err()
+		}
+	}
+}
+
+// shardFor maps a path to a stable worker index
+func shardFor(path string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(path))
+	return int(h.Sum32() % uint32(n))
+}
+
+func batchBytes(batch []client.Note) int64 {
+	var total int64
+	for _, n := range batch {
+		total += int64(len(n.Content))
+	}
+	return total
+}
+
+// limiter hands out at most one request slot per interval, shared by all
+// workers. A nil ticker means unlimited.
+type limiter struct {
+	ticker *time.Ticker
+}
+
+func newLimiter(perSecond float64) *limiter {
+	if perSecond <= 0 {
+		return &limiter{}
+	}
+	return &limiter{ticker: time.NewTicker(time.Duration(float64(time.Second) / perSecond))}
+}
+
+// wait blocks until the next slot, or returns ctx's error once it's done
+func (l *limiter) wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if l.ticker == nil {
+		return nil
+	}
+	select {
+	case <-l.ticker.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *limiter) stop() {
+	if l.ticker != nil {
+		l.ticker.Stop()
+	}
+}
@@ -0,0 +1,136 @@
+package syncer
+
+import (
+	"sort"
+
+	"github.com/daphen/notes-cli/internal/client"
+	"github.com/daphen/notes-cli/internal/hashing"
+	"github.com/daphen/notes-cli/internal/state"
+)
+
+// Divergence classifies how a note differs between laptop and server
+type Divergence string
+
+const (
+	InSync           Divergence = "in-sync"
+	LocalOnly        Divergence = "local-only"
+	RemoteOnly       Divergence = "remote-only"
+	ModifiedLocally  Divergence = "modified-locally"
+	ModifiedRemotely Divergence = "modified-remotely"
+	DeletedLocally   Divergence = "deleted-locally"
+	DeletedRemotely  Divergence = "deleted-remotely"
+	Conflicted       Divergence = "conflicted"
+)
+
+// NoteStatus is the comparison result for one path
+type NoteStatus struct {
+	Path   string     `json:"path"`
+	Status Divergence `json:"status"`
+}
+
+// Status is the comparison result for the whole vault
+type Status struct {
+	Notes []NoteStatus
+}
+
+// Compare classifies every note known locally or remotely. local maps paths
+// to their current content on disk; st provides the version last synced,
+// which decides which side changed when both differ, and tells a note
+// deleted on one side from one that is new on the other.
+func Compare(local map[string]string, remote []client.Note, st *state.State) *Status {
+	remoteByPath := make(map[string]client.Note, len(remote))
+	for _, n := range remote {
+		if n.DeletedAt == "" {
+			remoteByPath[n.Path] = n
+		}
+	}
+
+	paths := make(map[string]bool)
+	for p := range local {
+		paths[p] = true
+	}
+	for p := range remoteByPath {
+		paths[p] = true
+	}
+
+	status := &Status{}
+	for p := range paths {
+		status.Notes = append(status.Notes, NoteStatus{
+			Path:   p,
+			Status: classify(p, local, remoteByPath, st),
+		})
+	}
+
+	sort.Slice(status.Notes, func(i, j int) bool { return status.Notes[i].Path < status.Notes[j].Path })
+	return status
+}
+
+func classify(path string, local map[string]string, remote map[string]client.Note, st *state.State) Divergence {
+	localContent, hasLocal := local[path]
+	remoteNote, hasRemote := remote[path]
+	entry, known := st.Get(path)
+
+	// A synced note missing on one side was deleted there, unless the
+	// other side changed it since
+	switch {
+	case hasLocal && !hasRemote:
+		if !known {
+			return LocalOnly
+		}
+		if hashing.Matches(entry.Checksum, localContent) {
+			return DeletedRemotely
+		}
+		return Conflicted
+	case !hasLocal && hasRemote:
+		if !known {
+			return RemoteOnly
+		}
+		if hashing.Matches(entry.Checksum, remoteNote.Content) {
+			return DeletedLocally
+		}
+		return Conflicted
+	}
+
+	if localContent == remoteNote.Content {
+		return InSync
+	}
+
+	if !known {
+		// Never synced from here and the two sides disagree
+		return Conflicted
+	}
+
+	localChanged := !hashing.Matches(entry.Checksum, localContent)
+	remoteChanged := !hashing.Matches(entry.Checksum, remoteNote.Content)
+
+	switch {
+	case localChanged && remoteChanged:
+		return Conflicted
+	case localChanged:
+		return ModifiedLocally
+	case remoteChanged:
+		return ModifiedRemotely
+	}
+	return InSync
+}
+
+// Filter returns the paths with the given divergence
+func (s *Status) Filter(d Divergence) []string {
+	var paths []string
+	for _, n := range s.Notes {
+		if n.Status == d {
+			paths = append(paths, n.Path)
+		}
+	}
+	return paths
+}
+
+// Clean reports whether every note is in sync
+func (s *Status) Clean() bool {
+	for _, n := range s.Notes {
+		if n.Status != InSync {
+			return false
+		}
+	}
+	return true
+}
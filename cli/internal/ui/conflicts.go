@@ -0,0 +1,282 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/daphen/notes-cli/internal/diff"
+	"github.com/daphen/notes-cli/internal/theme"
+)
+
+// Conflict is a note changed both locally and on the server
+type Conflict struct {
+	Path   string
+	Local  string
+	Remote string
+}
+
+// ConflictResolver gives the TUI access to conflicts without depending on
+// the sync code. It is implemented in main.
+type ConflictResolver interface {
+	// Conflicts returns all unresolved conflicts
+	Conflicts() ([]Conflict, error)
+
+	// Resolve makes content the agreed version of path on both sides
+	Resolve(path, content string) error
+
+	// KeepBoth keeps the local version at path and saves the remote
+	// version as a new note, returning its path
+	KeepBoth(path string) (string, error)
+}
+
+// SyncModel lists conflicts and shows a diff of the selected one
+type SyncModel struct {
+	conflicts []Conflict
+	cursor    int
+	scroll    int // First visible diff line
+	width     int
+	height    int
+	theme     *theme.Theme
+
+	// Unfinished merges by note path, reopened by the next merge
+	drafts map[string]string
+}
+
+// NewSyncModel creates the conflict view
+func NewSyncModel(t *theme.Theme) SyncModel {
+	return SyncModel{theme: t, drafts: make(map[string]string)}
+}
+
+// SetDraft remembers the temp file holding an unfinished merge of path.
+// An empty draft forgets it.
+func (m *SyncModel) SetDraft(path, draft string) {
+	if draft == "" {
+		delete(m.drafts, path)
+		return
+	}
+	m.drafts[path] = draft
+}
+
+// Draft returns the unfinished merge of path, if any
+func (m SyncModel) Draft(path string) string {
+	return m.drafts[path]
+}
+
+// SetConflicts replaces the conflict list, keeping the cursor in range
+func (m *SyncModel) SetConflicts(conflicts []Conflict) {
+	m.conflicts = conflicts
+	if m.cursor >= len(conflicts) {
+		m.cursor = max(len(conflicts)-1, 0)
+	}
+	m.scroll = 0
+}
+
+// GetSelected returns the highlighted conflict
+func (m SyncModel) GetSelected() *Conflict {
+	if m.cursor >= len(m.conflicts) {
+		return nil
+	}
+	return &m.conflicts[m.cursor]
+}
+
+// Update handles messages for the conflict view
+func (m SyncModel) Update(msg tea.Msg) (SyncModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "up", "ctrl+k":
+			if m.cursor > 0 {
+				m.cursor--
+				m.scroll = 0
+			}
+
+		case "down", "ctrl+j":
+			if m.cursor < len(m.conflicts)-1 {
+				m.cursor++
+				m.scroll = 0
+			}
+
+		case "pgdown", "ctrl+d":
+			m.scroll += m.diffHeight() / 2
+
+		case "pgup", "ctrl+u":
+			m.scroll = max(m.scroll-m.diffHeight()/2, 0)
+		}
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+	}
+
+	return m, nil
+}
+
+// diffHeight is the number of diff lines that fit on screen
+func (m SyncModel) diffHeight() int {
+	h := m.height - len(m.conflicts) - 14
+	if h < 5 {
+		h = 5
+	}
+	return h
+}
+
+// View renders the conflict list and the diff of the selected conflict
+func (m SyncModel) View() string {
+	var b strings.Builder
+
+	b.WriteString(m.theme.HeaderStyle().Render(fmt.Sprintf("⚠ Conflicts (%d)", len(m.conflicts))))
+	b.WriteString("\n\n")
+
+	if len(m.conflicts) == 0 {
+		b.WriteString(m.theme.SuccessStyle().Render("No conflicts - everything is in sync."))
+		b.WriteString("\n")
+		return b.String()
+	}
+
+	for i, c := range m.conflicts {
+		if i == m.cursor {
+			b.WriteString(m.theme.SelectedStyle().Render("▶ " + c.Path))
+		} else {
+			b.WriteString(m.theme.NormalStyle().Render("  " + c.Path))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	selected := m.GetSelected()
+	lines := strings.Split(strings.TrimSuffix(
+		diff.Unified(selected.Local, selected.Remote, "local", "server", 3), "\n"), "\n")
+
+	// Clamp scrolling to the diff length
+	height := m.diffHeight()
+	scroll := min(m.scroll, max(len(lines)-height, 0))
+	end := min(scroll+height, len(lines))
+
+	removed := lipgloss.NewStyle().Foreground(lipgloss.Color(m.theme.Colors.Accent.Red))
+	added := lipgloss.NewStyle().Foreground(lipgloss.Color(m.theme.Colors.Accent.Green))
+
+	var diffView strings.Builder
+	for _, line := range lines[scroll:end] {
+		switch {
+		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "@@"):
+			diffView.WriteString(m.theme.AccentStyle().Render(line))
+		case strings.HasPrefix(line, "-"):
+			diffView.WriteString(removed.Render(line))
+		case strings.HasPrefix(line, "+"):
+			diffView.WriteString(added.Render(line))
+		default:
+			diffView.WriteString(m.theme.NormalStyle().Render(line))
+		}
+		diffView.WriteString("\n")
+	}
+
+	borderStyle := lipgloss.NewStyle().
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(m.theme.Colors.Accent.Yellow)).
+		Padding(0, 1).
+		Width(m.width - 4)
+	b.WriteString(borderStyle.Render(strings.TrimSuffix(diffView.String(), "\n")))
+	b.WriteString("\n")
+
+	if len(lines) > height {
+		b.WriteString(m.theme.MutedStyle().Render(fmt.Sprintf("lines %d-%d of %d • PgUp/PgDn to scroll", scroll+1, end, len(lines))))
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+type conflictsLoadedMsg struct {
+	conflicts []Conflict
+	err       error
+}
+
+type conflictResolvedMsg struct {
+	path    string
+	message string // What was done, shown in the footer
+	draft   string // Merge left unfinished, kept for the next attempt
+	err     error
+}
+
+type conflictsChangedMsg struct{}
+
+// SendConflictsChanged asks the TUI to reload the conflict list
+func SendConflictsChanged() tea.Msg {
+	return conflictsChangedMsg{}
+}
+
+func loadConflicts(resolver ConflictResolver) tea.Cmd {
+	return func() tea.Msg {
+		conflicts, err := resolver.Conflicts()
+		return conflictsLoadedMsg{conflicts: conflicts, err: err}
+	}
+}
+
+// resolveConflict applies one of the quick resolutions
+func resolveConflict(resolver ConflictResolver, c Conflict, action string) tea.Cmd {
+	return func() tea.Msg {
+		switch action {
+		case "local":
+			err := resolver.Resolve(c.Path, c.Local)
+			return conflictResolvedMsg{path: c.Path, message: "Kept local version of " + c.Path, err: err}
+
+		case "remote":
+			err := resolver.Resolve(c.Path, c.Remote)
+			return conflictResolvedMsg{path: c.Path, message: "Kept server version of " + c.Path, err: err}
+
+		case "both":
+			copyPath, err := resolver.KeepBoth(c.Path)
+			return conflictResolvedMsg{path: c.Path, message: "Saved server version as " + copyPath, err: err}
+		}
+		return nil
+	}
+}
+
+// openMergeInEditor writes both versions with conflict markers to a temp
+// file and resolves the conflict with whatever the user saves, as long as
+// no markers are left. A merge that isn't finished is kept, and draft, if
+// set, reopens it instead of starting over.
+func openMergeInEditor(resolver ConflictResolver, c Conflict, editor, draft string) tea.Cmd {
+	tmpPath := draft
+	if _, err := os.Stat(tmpPath); draft == "" || err != nil {
+		tmp, err := os.CreateTemp("", "notes-merge-*.md")
+		if err != nil {
+			return func() tea.Msg { return conflictResolvedMsg{path: c.Path, err: err} }
+		}
+		tmpPath = tmp.Name()
+		_, err = tmp.WriteString(diff.Merge(c.Local, c.Remote, "local", "server"))
+		tmp.Close()
+		if err != nil {
+			os.Remove(tmpPath)
+			return func() tea.Msg { return conflictResolvedMsg{path: c.Path, err: err} }
+		}
+	}
+
+	return tea.ExecProcess(exec.Command(editor, tmpPath), func(err error) tea.Msg {
+		if err != nil {
+			return conflictResolvedMsg{path: c.Path, draft: tmpPath, err: err}
+		}
+
+		merged, err := os.ReadFile(tmpPath)
+		if err != nil {
+			return conflictResolvedMsg{path: c.Path, draft: tmpPath, err: err}
+		}
+
+		if diff.HasMarkers(string(merged)) {
+			return conflictResolvedMsg{path: c.Path, draft: tmpPath,
+				err: fmt.Errorf("merge still contains conflict markers - not saved, m continues it (%s)", tmpPath)}
+		}
+
+		if err := resolver.Resolve(c.Path, string(merged)); err != nil {
+			return conflictResolvedMsg{path: c.Path, draft: tmpPath, err: err}
+		}
+
+		os.Remove(tmpPath)
+		return conflictResolvedMsg{path: c.Path, message: "Merged " + c.Path}
+	})
+}
@@ -0,0 +1,179 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/daphen/notes-cli/internal/journal"
+	"github.com/daphen/notes-cli/internal/theme"
+)
+
+// LogModel shows the sync journal, newest first, filtered by typed words
+type LogModel struct {
+	entries  []journal.Entry // Newest first
+	filtered []journal.Entry
+	filter   string
+	scroll   int // First visible entry
+	width    int
+	height   int
+	theme    *theme.Theme
+}
+
+// NewLogModel creates the sync log view
+func NewLogModel(t *theme.Theme) LogModel {
+	return LogModel{theme: t}
+}
+
+// SetEntries replaces the journal entries (given oldest first)
+func (m *LogModel) SetEntries(entries []journal.Entry) {
+	m.entries = make([]journal.Entry, len(entries))
+	for i, e := range entries {
+		m.entries[len(entries)-1-i] = e
+	}
+	m.updateFilter()
+}
+
+func (m *LogModel) updateFilter() {
+	m.filtered = m.filtered[:0]
+	for _, e := range m.entries {
+		if e.Matches(m.filter) {
+			m.filtered = append(m.filtered, e)
+		}
+	}
+	m.scroll = min(m.scroll, max(len(m.filtered)-1, 0))
+}
+
+// Update handles messages for the log view
+func (m LogModel) Update(msg tea.Msg) (LogModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "up", "ctrl+k":
+			if m.scroll > 0 {
+				m.scroll--
+			}
+
+		case "down", "ctrl+j":
+			if m.scroll < len(m.filtered)-1 {
+				m.scroll++
+			}
+
+		case "pgup":
+			m.scroll = max(m.scroll-m.visibleHeight(), 0)
+
+		case "pgdown":
+			m.scroll = max(min(m.scroll+m.visibleHeight(), len(m.filtered)-1), 0)
+
+		case "backspace":
+			if len(m.filter) > 0 {
+				m.filter = m.filter[:len(m.filter)-1]
+				m.updateFilter()
+			}
+
+		case "ctrl+u":
+			m.filter = ""
+			m.updateFilter()
+
+		default:
+			if len(msg.Runes) == 1 {
+				r := msg.Runes[0]
+				if r >= 32 && r != 127 {
+					m.filter += string(r)
+					m.scroll = 0
+					m.updateFilter()
+				}
+			}
+		}
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+	}
+
+	return m, nil
+}
+
+func (m LogModel) visibleHeight() int {
+	h := m.height - 12
+	if h < 5 {
+		h = 5
+	}
+	return h
+}
+
+// View renders the log view
+func (m LogModel) View() string {
+	var b strings.Builder
+
+	filterLine := m.theme.SearchLabelStyle().Render("Filter: ") + m.filter
+	filterLine += m.theme.AccentStyle().Blink(true).Render("█")
+	b.WriteString(filterLine)
+	b.WriteString("\n\n")
+
+	b.WriteString(m.theme.MutedStyle().Render(fmt.Sprintf("%d of %d sync events", len(m.filtered), len(m.entries))))
+	b.WriteString("\n\n")
+
+	var list strings.Builder
+	end := min(m.scroll+m.visibleHeight(), len(m.filtered))
+	for _, e := range m.filtered[m.scroll:end] {
+		list.WriteString(m.renderEntry(e))
+		list.WriteString("\n")
+	}
+	if len(m.filtered) == 0 {
+		list.WriteString(m.theme.MutedStyle().Render("  No sync activity recorded yet."))
+		list.WriteString("\n")
+	}
+
+	borderStyle := lipgloss.NewStyle().
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(m.theme.Colors.Accent.Blue)).
+		Padding(0, 1).
+		Width(m.width - 4)
+	b.WriteString(borderStyle.Render(strings.TrimSuffix(list.String(), "\n")))
+
+	return b.String()
+}
+
+func (m LogModel) renderEntry(e journal.Entry) string {
+	arrow := "↑"
+	if e.Direction == journal.Pull {
+		arrow = "↓"
+	}
+
+	var result string
+	switch e.Result {
+	case journal.OK:
+		result = m.theme.SuccessStyle().Render("✓")
+	case journal.Conflict:
+		result = m.theme.AccentStyle().Render("⚠")
+	case journal.Skipped:
+		result = m.theme.MutedStyle().Render("-")
+	default:
+		result = m.theme.ErrorStyle().Render("✗")
+	}
+
+	line := m.theme.MutedStyle().Render(e.Time.Local().Format("Jan 02 15:04:05")) +
+		" " + arrow + " " + result + " " + m.theme.NormalStyle().Render(e.Path)
+	if e.Action != "" && e.Action != "update" {
+		line += m.theme.MutedStyle().Render(" (" + e.Action + ")")
+	}
+	if e.Error != "" {
+		line += " " + m.theme.ErrorStyle().Render(e.Error)
+	}
+	return line
+}
+
+type journalLoadedMsg struct {
+	entries []journal.Entry
+	err     error
+}
+
+func loadJournal(j *journal.Journal) tea.Cmd {
+	return func() tea.Msg {
+		entries, err := j.Read()
+		return journalLoadedMsg{entries: entries, err: err}
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 
+	"github.com/daphen/notes-cli/internal/journal"
 	"github.com/daphen/notes-cli/internal/note"
 	"github.com/daphen/notes-cli/internal/theme"
 )
@@ -19,8 +20,11 @@ type ViewMode int
 
 const (
 	ViewBrowse ViewMode = iota // Browse/search notes
-	ViewCreate                  // Quick note creation
-	ViewSync                    // Sync status (background)
+	ViewCreate                 // Quick note creation
+	ViewSync                   // Conflict resolution
+	ViewUnlock                 // Passphrase prompt for encrypted notes
+	ViewLog                    // Sync journal
+	ViewVaults                 // Vault switcher
 )
 
 // 🔵 GO CONCEPT: iota
@@ -33,14 +37,34 @@ type Model struct {
 	currentView ViewMode
 	browse      BrowseModel
 	create      CreateModel
+	unlock      UnlockModel
+	sync        SyncModel
+	log         LogModel
+	vaults      VaultsModel
 
 	// Sync state (runs in background)
-	syncStatus   string
-	syncMessages []string
-	watching     bool
-	lastSync     time.Time
-	loading      bool
-	syncing      bool // Currently syncing with server
+	syncStatus string
+	watching   bool
+	lastSync   time.Time
+	loading    bool
+	syncing    bool // Currently syncing with server
+
+	// Sync history shown in the log view (nil when not syncing)
+	journal *journal.Journal
+
+	// Conflict resolution (nil when not syncing)
+	resolver  ConflictResolver
+	conflicts int
+	resolved  string // What the last resolution did, shown until the next key
+
+	// Vault open in the TUI; switchTo is set when the user picks another
+	// one, which ends the program so main can reopen it
+	vaultName  string
+	vaultCount int
+	switchTo   string
+
+	// Session passphrase for encrypted notes (empty = locked)
+	passphrase string
 
 	// Config
 	notesDir   string
@@ -74,17 +98,20 @@ func NewModel(notesDir string) Model {
 	themeObj, _ := theme.Load()
 
 	return Model{
-		currentView:  ViewBrowse,
-		browse:       NewBrowseModel(themeObj),
-		create:       NewCreateModel(themeObj),
-		syncStatus:   "Ready",
-		syncMessages: []string{},
-		watching:     false,
-		lastSync:     time.Now(),
-		loading:      true, // Start in loading state
-		notesDir:     notesDir,
-		editorPath:   editor,
-		theme:        themeObj,
+		currentView: ViewBrowse,
+		browse:      NewBrowseModel(themeObj),
+		create:      NewCreateModel(themeObj),
+		unlock:      NewUnlockModel(themeObj),
+		sync:        NewSyncModel(themeObj),
+		log:         NewLogModel(themeObj),
+		vaults:      NewVaultsModel(themeObj),
+		syncStatus:  "Ready",
+		watching:    false,
+		lastSync:    time.Now(),
+		loading:     true, // Start in loading state
+		notesDir:    notesDir,
+		editorPath:  editor,
+		theme:       themeObj,
 	}
 }
 
@@ -93,6 +120,31 @@ func (m *Model) SetProgram(p *tea.Program) {
 	m.program = p
 }
 
+// SetConflictResolver enables the conflict view. Call before the program
+// starts, since tea.NewProgram copies the model.
+func (m *Model) SetConflictResolver(r ConflictResolver) {
+	m.resolver = r
+}
+
+// SetJournal enables the sync log view. Like SetConflictResolver, call
+// before the program starts.
+func (m *Model) SetJournal(j *journal.Journal) {
+	m.journal = j
+}
+
+// SetVaults enables the vault switcher when more than one vault is
+// configured. Call before the program starts.
+func (m *Model) SetVaults(names []string, current string) {
+	m.vaultName = current
+	m.vaultCount = len(names)
+	m.vaults.SetVaults(names, current)
+}
+
+// SwitchTo returns the vault chosen in the switcher, or "" if the user quit
+func (m Model) SwitchTo() string {
+	return m.switchTo
+}
+
 // SetCreateView switches the model to create view mode
 func (m *Model) SetCreateView() {
 	m.currentView = ViewCreate
@@ -113,6 +165,11 @@ type editorFinishedMsg struct {
 	err error
 }
 
+type noteUnlockedMsg struct {
+	path       string
+	passphrase string
+}
+
 type syncStatusMsg string
 
 type syncSuccessMsg struct {
@@ -125,13 +182,18 @@ type syncErrorMsg struct {
 
 type syncStartMsg struct{}
 type syncEndMsg struct{}
+type notesChangedMsg struct{}
 
 // Init is called once when the program starts
 func (m Model) Init() tea.Cmd {
-	return tea.Batch(
-		loadNotes(m.notesDir),
+	cmds := []tea.Cmd{
+		loadNotes(m.notesDir, m.passphrase),
 		tickEverySecond(),
-	)
+	}
+	if m.resolver != nil {
+		cmds = append(cmds, loadConflicts(m.resolver))
+	}
+	return tea.Batch(cmds...)
 }
 
 // Update handles messages and updates the model
@@ -141,6 +203,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		m.resolved = ""
+
 		// Global keys (work in any view)
 		switch msg.String() {
 		case "ctrl+c", "ctrl+q":
@@ -165,13 +229,82 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 
+		case "ctrl+s":
+			// Review conflicts with the server
+			if m.currentView == ViewBrowse && m.resolver != nil {
+				m.currentView = ViewSync
+				return m, loadConflicts(m.resolver)
+			}
+
+		case "ctrl+o":
+			// Show sync history
+			if m.currentView == ViewBrowse && m.journal != nil {
+				m.currentView = ViewLog
+				return m, loadJournal(m.journal)
+			}
+
+		case "ctrl+t":
+			// Switch vault
+			if m.currentView == ViewBrowse && m.vaultCount > 1 {
+				m.currentView = ViewVaults
+				return m, nil
+			}
+
+		case "l", "r", "b", "m":
+			if m.currentView == ViewSync {
+				selected := m.sync.GetSelected()
+				if selected == nil {
+					return m, nil
+				}
+				switch msg.String() {
+				case "l":
+					return m, resolveConflict(m.resolver, *selected, "local")
+				case "r":
+					return m, resolveConflict(m.resolver, *selected, "remote")
+				case "b":
+					return m, resolveConflict(m.resolver, *selected, "both")
+				case "m":
+					return m, openMergeInEditor(m.resolver, *selected, m.editorPath, m.sync.Draft(selected.Path))
+				}
+			}
+
+		case "ctrl+l":
+			// Forget the session passphrase and hide encrypted content again
+			if m.currentView == ViewBrowse && m.passphrase != "" {
+				m.passphrase = ""
+				return m, loadNotes(m.notesDir, m.passphrase)
+			}
+
 		case "enter":
 			if m.currentView == ViewBrowse {
 				// Open selected note in editor
 				selected := m.browse.GetSelectedNote()
 				if selected != nil {
-					return m, openInEditor(m.notesDir, selected.Path, m.editorPath)
+					if !isEncryptedNote(filepath.Join(m.notesDir, selected.Path)) {
+						return m, openInEditor(m.notesDir, selected.Path, m.editorPath)
+					}
+					if m.passphrase == "" {
+						m.currentView = ViewUnlock
+						m.unlock.Reset(selected.Path, !isSealedNote(filepath.Join(m.notesDir, selected.Path)))
+						return m, nil
+					}
+					return m, openEncryptedInEditor(m.notesDir, selected.Path, m.editorPath, m.passphrase)
+				}
+			} else if m.currentView == ViewVaults {
+				selected := m.vaults.GetSelected()
+				if selected == "" || selected == m.vaultName {
+					m.currentView = ViewBrowse
+					return m, nil
+				}
+				m.switchTo = selected
+				return m, tea.Quit
+			} else if m.currentView == ViewUnlock {
+				passphrase, ready, err := m.unlock.Submit()
+				m.err = err
+				if !ready {
+					return m, nil
 				}
+				return m, unlockNote(m.notesDir, m.unlock.GetPath(), passphrase)
 			} else if m.currentView == ViewCreate {
 				// Save the new note
 				return m, createNote(m.notesDir, m.create.GetFilename(), m.create.GetTitle(), m.create.GetContent())
@@ -189,6 +322,26 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			var cmd tea.Cmd
 			m.create, cmd = m.create.Update(msg)
 			return m, cmd
+
+		case ViewUnlock:
+			var cmd tea.Cmd
+			m.unlock, cmd = m.unlock.Update(msg)
+			return m, cmd
+
+		case ViewSync:
+			var cmd tea.Cmd
+			m.sync, cmd = m.sync.Update(msg)
+			return m, cmd
+
+		case ViewLog:
+			var cmd tea.Cmd
+			m.log, cmd = m.log.Update(msg)
+			return m, cmd
+
+		case ViewVaults:
+			var cmd tea.Cmd
+			m.vaults, cmd = m.vaults.Update(msg)
+			return m, cmd
 		}
 
 	case tea.WindowSizeMsg:
@@ -196,16 +349,19 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.height = msg.Height
 		m.browse, _ = m.browse.Update(msg)
 		m.create, _ = m.create.Update(msg)
+		m.unlock, _ = m.unlock.Update(msg)
+		m.sync, _ = m.sync.Update(msg)
+		m.log, _ = m.log.Update(msg)
+		m.vaults, _ = m.vaults.Update(msg)
 
 	case notesLoadedMsg:
 		m.loading = false
 		m.browse.SetNotes(msg.notes)
 
 	case noteCreatedMsg:
-		m.syncMessages = append(m.syncMessages, fmt.Sprintf("✓ Created: %s", msg.path))
 		m.currentView = ViewBrowse
 		return m, tea.Batch(
-			loadNotes(m.notesDir), // Reload list
+			loadNotes(m.notesDir, m.passphrase),              // Reload list
 			openInEditor(m.notesDir, msg.path, m.editorPath), // Open in editor
 		)
 
@@ -214,23 +370,28 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.err = msg.err
 		}
 		// Reload notes after editing
-		return m, loadNotes(m.notesDir)
+		return m, loadNotes(m.notesDir, m.passphrase)
+
+	case noteUnlockedMsg:
+		m.passphrase = msg.passphrase
+		m.err = nil
+		m.currentView = ViewBrowse
+		return m, tea.Batch(
+			loadNotes(m.notesDir, m.passphrase), // Include decrypted content in search
+			openEncryptedInEditor(m.notesDir, msg.path, m.editorPath, m.passphrase),
+		)
 
 	case syncStatusMsg:
 		m.syncStatus = string(msg)
 
 	case syncSuccessMsg:
-		m.syncMessages = append(m.syncMessages, fmt.Sprintf("✓ Synced: %s", msg.file))
 		m.lastSync = time.Now()
-		// Keep only last 10 messages
-		if len(m.syncMessages) > 10 {
-			m.syncMessages = m.syncMessages[len(m.syncMessages)-10:]
-		}
+		return m, m.refreshLog()
 
 	case syncErrorMsg:
 		m.err = msg.err
 		m.syncing = false
-		m.syncMessages = append(m.syncMessages, fmt.Sprintf("✗ Error: %s", msg.err))
+		return m, m.refreshLog()
 
 	case syncStartMsg:
 		m.syncing = true
@@ -241,6 +402,47 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case syncEndMsg:
 		m.syncing = false
 
+	case notesChangedMsg:
+		// Remote changes were written to disk - refresh the list
+		return m, loadNotes(m.notesDir, m.passphrase)
+
+	case conflictsChangedMsg:
+		if m.resolver != nil {
+			return m, loadConflicts(m.resolver)
+		}
+
+	case conflictsLoadedMsg:
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.conflicts = len(msg.conflicts)
+		m.sync.SetConflicts(msg.conflicts)
+
+	case conflictResolvedMsg:
+		// A merge left unfinished is no longer needed once resolved
+		if draft := m.sync.Draft(msg.path); msg.err == nil && draft != "" {
+			os.Remove(draft)
+		}
+		m.sync.SetDraft(msg.path, msg.draft)
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.err = nil
+		m.resolved = msg.message
+		return m, tea.Batch(
+			loadConflicts(m.resolver),
+			loadNotes(m.notesDir, m.passphrase),
+		)
+
+	case journalLoadedMsg:
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.log.SetEntries(msg.entries)
+
 	case tickMsg:
 		// If loading or syncing, request faster ticks for spinner animation
 		if m.loading || m.syncing {
@@ -260,6 +462,9 @@ func (m Model) View() string {
 
 	// Header
 	title := "NOTES"
+	if m.vaultCount > 1 {
+		title += " · " + m.vaultName
+	}
 	if m.watching {
 		title += " (watching)"
 	}
@@ -281,6 +486,18 @@ func (m Model) View() string {
 
 		case ViewCreate:
 			b.WriteString(m.create.View())
+
+		case ViewUnlock:
+			b.WriteString(m.unlock.View())
+
+		case ViewSync:
+			b.WriteString(m.sync.View())
+
+		case ViewLog:
+			b.WriteString(m.log.View())
+
+		case ViewVaults:
+			b.WriteString(m.vaults.View())
 		}
 	}
 
@@ -292,19 +509,38 @@ func (m Model) View() string {
 	if m.syncing {
 		spinner := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
 		frame := int(time.Now().UnixNano()/100000000) % len(spinner)
-		syncInfo = m.theme.AccentStyle().Render(spinner[frame]+" Syncing...")
+		syncInfo = m.theme.AccentStyle().Render(spinner[frame] + " Syncing...")
 	} else {
 		timeSince := time.Since(m.lastSync)
 		syncInfo = fmt.Sprintf("Last sync: %s ago", formatDuration(timeSince))
 	}
 
 	if m.currentView == ViewBrowse {
+		keys := " • Ctrl+N: create • Ctrl+Q: quit"
+		if m.passphrase != "" {
+			keys = " • Ctrl+N: create • Ctrl+L: lock • Ctrl+Q: quit"
+		}
+		if m.journal != nil {
+			keys = " • Ctrl+O: log" + keys
+		}
+		if m.vaultCount > 1 {
+			keys = " • Ctrl+T: vaults" + keys
+		}
+		if m.conflicts > 0 {
+			keys = fmt.Sprintf(" • ⚠ %d conflicts • Ctrl+S: resolve", m.conflicts) + keys
+		}
 		if m.syncing {
-			b.WriteString(syncInfo + m.theme.MutedStyle().Render(" • Ctrl+N: create • Ctrl+Q: quit"))
+			b.WriteString(syncInfo + m.theme.MutedStyle().Render(keys))
 		} else {
-			b.WriteString(m.theme.MutedStyle().Render(syncInfo + " • Ctrl+N: create • Ctrl+Q: quit"))
+			b.WriteString(m.theme.MutedStyle().Render(syncInfo + keys))
 		}
-	} else if m.currentView == ViewCreate {
+	} else if m.currentView == ViewVaults {
+		b.WriteString(m.theme.MutedStyle().Render("Enter: open vault • Esc: back"))
+	} else if m.currentView == ViewLog {
+		b.WriteString(m.theme.MutedStyle().Render("Type to filter (e.g. \"pull error\") • ↑/↓ to scroll • Esc: back"))
+	} else if m.currentView == ViewSync {
+		b.WriteString(m.theme.MutedStyle().Render("l: keep local • r: keep server • b: keep both • m: merge in editor • Esc: back"))
+	} else if m.currentView == ViewCreate || m.currentView == ViewUnlock {
 		b.WriteString(m.theme.MutedStyle().Render("Esc to cancel"))
 	}
 
@@ -312,14 +548,25 @@ func (m Model) View() string {
 	if m.err != nil {
 		b.WriteString("\n")
 		b.WriteString(m.theme.ErrorStyle().Render(fmt.Sprintf("Error: %v", m.err)))
+	} else if m.resolved != "" {
+		b.WriteString("\n")
+		b.WriteString(m.theme.SuccessStyle().Render("✓ " + m.resolved))
 	}
 
 	return b.String()
 }
 
+// refreshLog reloads the journal while the log view is open
+func (m Model) refreshLog() tea.Cmd {
+	if m.currentView != ViewLog || m.journal == nil {
+		return nil
+	}
+	return loadJournal(m.journal)
+}
+
 // Helper commands
 
-func loadNotes(notesDir string) tea.Cmd {
+func loadNotes(notesDir, passphrase string) tea.Cmd {
 	return func() tea.Msg {
 		var notes []NoteItem
 
@@ -353,6 +600,16 @@ func loadNotes(notesDir string) tea.Cmd {
 				contentStr := string(content)
 				title := note.ExtractTitle(contentStr, relPath)
 
+				// Encrypted notes are only searchable once unlocked
+				if note.IsEncrypted(contentStr) {
+					contentStr = ""
+					if passphrase != "" {
+						if plain, err := note.Unlock(string(content), passphrase); err == nil {
+							contentStr = plain
+						}
+					}
+				}
+
 				notes = append(notes, NoteItem{
 					Path:    relPath,
 					Title:   title,
@@ -409,6 +666,111 @@ func openInEditor(notesDir, notePath, editor string) tea.Cmd {
 	})
 }
 
+// isEncryptedNote reports whether the note on disk is marked encrypted
+func isEncryptedNote(fullPath string) bool {
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		return false
+	}
+	return note.IsEncrypted(string(content))
+}
+
+// isSealedNote reports whether the note on disk already has an encrypted
+// body, as opposed to being marked encrypted but not sealed yet
+func isSealedNote(fullPath string) bool {
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		return false
+	}
+	return note.IsSealed(string(content))
+}
+
+// unlockNote checks the passphrase against the note before it is
+// remembered for the session
+func unlockNote(notesDir, notePath, passphrase string) tea.Cmd {
+	return func() tea.Msg {
+		if passphrase == "" {
+			return syncErrorMsg{err: errEmptyPassphrase}
+		}
+
+		content, err := os.ReadFile(filepath.Join(notesDir, notePath))
+		if err != nil {
+			return syncErrorMsg{err: err}
+		}
+
+		if _, err := note.Unlock(string(content), passphrase); err != nil {
+			return syncErrorMsg{err: err}
+		}
+
+		return noteUnlockedMsg{path: notePath, passphrase: passphrase}
+	}
+}
+
+// openEncryptedInEditor decrypts the note into a private temp file, opens it
+// in the editor and seals the result back into the note when the editor exits.
+// Notes marked encrypted but still in plaintext get sealed on their first save.
+// The temp file lives in a directory only this user can read, under
+// $XDG_RUNTIME_DIR when set, and is only removed once the note has been
+// written, so edits survive a failed seal or editor.
+func openEncryptedInEditor(notesDir, notePath, editor, passphrase string) tea.Cmd {
+	fullPath := filepath.Join(notesDir, notePath)
+
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		return func() tea.Msg { return editorFinishedMsg{err: err} }
+	}
+
+	plain, err := note.Unlock(string(content), passphrase)
+	if err != nil {
+		return func() tea.Msg { return editorFinishedMsg{err: err} }
+	}
+
+	// os.MkdirTemp creates the directory with 0700 permissions
+	tmpDir, err := os.MkdirTemp(os.Getenv("XDG_RUNTIME_DIR"), "notes-locked-*")
+	if err != nil {
+		return func() tea.Msg { return editorFinishedMsg{err: err} }
+	}
+	tmpPath := filepath.Join(tmpDir, filepath.Base(notePath))
+	if err := os.WriteFile(tmpPath, []byte(plain), 0600); err != nil {
+		os.RemoveAll(tmpDir)
+		return func() tea.Msg { return editorFinishedMsg{err: err} }
+	}
+
+	return tea.ExecProcess(exec.Command(editor, tmpPath), func(err error) tea.Msg {
+		if err != nil {
+			return editorFinishedMsg{err: fmt.Errorf("%w (edits kept in %s)", err, tmpPath)}
+		}
+
+		edited, err := os.ReadFile(tmpPath)
+		if err != nil {
+			os.RemoveAll(tmpDir)
+			return editorFinishedMsg{err: err}
+		}
+
+		// Unchanged and already sealed - keep the existing ciphertext
+		if string(edited) == plain && note.IsSealed(string(content)) {
+			os.RemoveAll(tmpDir)
+			return editorFinishedMsg{}
+		}
+
+		// Re-check in case encrypted: true was removed while editing
+		result := string(edited)
+		if note.IsEncrypted(result) {
+			result, err = note.Lock(result, passphrase)
+			if err != nil {
+				return editorFinishedMsg{err: fmt.Errorf("%w (edits kept in %s)", err, tmpPath)}
+			}
+		}
+
+		if err := os.WriteFile(fullPath, []byte(result), 0644); err != nil {
+			return editorFinishedMsg{err: fmt.Errorf("%w (edits kept in %s)", err, tmpPath)}
+		}
+
+		os.RemoveAll(tmpDir)
+		return editorFinishedMsg{}
+	})
+}
+
 type tickMsg time.Time
 
 func tickEverySecond() tea.Cmd {
@@ -451,3 +813,8 @@ func SendSyncStart() tea.Msg {
 func SendSyncEnd() tea.Msg {
 	return syncEndMsg{}
 }
+
+// SendNotesChanged asks the TUI to reload the note list from disk
+func SendNotesChanged() tea.Msg {
+	return notesChangedMsg{}
+}
@@ -0,0 +1,135 @@
+package ui
+
+import (
+	"errors"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/daphen/notes-cli/internal/theme"
+)
+
+var (
+	errEmptyPassphrase    = errors.New("passphrase can't be empty")
+	errPassphraseMismatch = errors.New("passphrases don't match, try again")
+)
+
+// UnlockModel prompts for the passphrase of an encrypted note
+type UnlockModel struct {
+	path       string // Note waiting to be opened once unlocked
+	passphrase string
+	sealing    bool   // Note isn't sealed yet, the passphrase will seal it
+	confirming bool   // Waiting for the passphrase to be typed again
+	first      string // Passphrase typed before confirming
+	width      int
+	theme      *theme.Theme
+}
+
+// NewUnlockModel creates a new passphrase prompt
+func NewUnlockModel(t *theme.Theme) UnlockModel {
+	return UnlockModel{theme: t}
+}
+
+// Reset clears the prompt and remembers which note to open. sealing is
+// set for notes that have never been sealed, whose passphrase is chosen
+// here rather than checked.
+func (m *UnlockModel) Reset(path string, sealing bool) {
+	m.path = path
+	m.passphrase = ""
+	m.sealing = sealing
+	m.confirming = false
+	m.first = ""
+}
+
+// Submit handles Enter and returns the passphrase once it's ready to use.
+// A new passphrase must be non-empty and typed twice - a typo would leave
+// the note unrecoverable.
+func (m *UnlockModel) Submit() (string, bool, error) {
+	if !m.sealing {
+		return m.passphrase, true, nil
+	}
+	if m.passphrase == "" {
+		return "", false, errEmptyPassphrase
+	}
+	if !m.confirming {
+		m.first, m.passphrase, m.confirming = m.passphrase, "", true
+		return "", false, nil
+	}
+	if m.passphrase != m.first {
+		m.Reset(m.path, true)
+		return "", false, errPassphraseMismatch
+	}
+	return m.passphrase, true, nil
+}
+
+// Update handles messages for the unlock view
+func (m UnlockModel) Update(msg tea.Msg) (UnlockModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "backspace":
+			if len(m.passphrase) > 0 {
+				runes := []rune(m.passphrase)
+				m.passphrase = string(runes[:len(runes)-1])
+			}
+
+		case "ctrl+u":
+			m.passphrase = ""
+
+		default:
+			for _, r := range msg.Runes {
+				if r >= 32 && r != 127 {
+					m.passphrase += string(r)
+				}
+			}
+		}
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+	}
+
+	return m, nil
+}
+
+// View renders the passphrase prompt. The passphrase itself is never shown.
+func (m UnlockModel) View() string {
+	var b strings.Builder
+
+	b.WriteString(m.theme.HeaderStyle().Render("🔒 Encrypted Note"))
+	b.WriteString("\n\n")
+	b.WriteString(m.theme.MutedStyle().Render(m.path))
+	b.WriteString("\n\n")
+	label, help := "Passphrase:", "Enter to unlock for this session • Esc to cancel"
+	if m.sealing {
+		label, help = "New passphrase:", "Enter to continue • Esc to cancel"
+		if m.confirming {
+			label, help = "Confirm passphrase:", "Enter to seal the note on save • Esc to cancel"
+		}
+	}
+	b.WriteString(m.theme.SuccessStyle().Bold(true).Render(label))
+	b.WriteString("\n")
+
+	boxStyle := m.theme.ActiveBorderStyle().
+		Border(lipgloss.RoundedBorder()).
+		Padding(0, 1).
+		Width(m.width - 4)
+
+	masked := strings.Repeat("•", len([]rune(m.passphrase)))
+	b.WriteString(boxStyle.Render(masked + m.theme.MutedStyle().Render("█")))
+	b.WriteString("\n\n")
+
+	b.WriteString(m.theme.MutedStyle().Render(help))
+
+	return b.String()
+}
+
+// GetPath returns the note waiting to be unlocked
+func (m UnlockModel) GetPath() string {
+	return m.path
+}
+
+// GetPassphrase returns the typed passphrase
+func (m UnlockModel) GetPassphrase() string {
+	return m.passphrase
+}
@@ -0,0 +1,98 @@
+package ui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/daphen/notes-cli/internal/theme"
+)
+
+// VaultsModel lists the configured vaults to switch between
+type VaultsModel struct {
+	names   []string
+	current string
+	cursor  int
+	width   int
+	theme   *theme.Theme
+}
+
+// NewVaultsModel creates the vault switcher
+func NewVaultsModel(t *theme.Theme) VaultsModel {
+	return VaultsModel{theme: t}
+}
+
+// SetVaults sets the vault names and which one is open
+func (m *VaultsModel) SetVaults(names []string, current string) {
+	m.names = names
+	m.current = current
+	m.cursor = 0
+	for i, name := range names {
+		if name == current {
+			m.cursor = i
+		}
+	}
+}
+
+// GetSelected returns the highlighted vault name
+func (m VaultsModel) GetSelected() string {
+	if m.cursor >= len(m.names) {
+		return ""
+	}
+	return m.names[m.cursor]
+}
+
+// Update handles messages for the vault switcher
+func (m VaultsModel) Update(msg tea.Msg) (VaultsModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "up", "ctrl+k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+
+		case "down", "ctrl+j":
+			if m.cursor < len(m.names)-1 {
+				m.cursor++
+			}
+		}
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+	}
+
+	return m, nil
+}
+
+// View renders the vault list
+func (m VaultsModel) View() string {
+	var b strings.Builder
+
+	b.WriteString(m.theme.HeaderStyle().Render("Switch vault"))
+	b.WriteString("\n\n")
+
+	var list strings.Builder
+	for i, name := range m.names {
+		label := name
+		if name == m.current {
+			label += " (open)"
+		}
+		if i == m.cursor {
+			list.WriteString(m.theme.SelectedStyle().Render("▶ " + label))
+		} else {
+			list.WriteString(m.theme.NormalStyle().Render("  " + label))
+		}
+		list.WriteString("\n")
+	}
+
+	borderStyle := lipgloss.NewStyle().
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(m.theme.Colors.Accent.Blue)).
+		Padding(1, 2).
+		Width(m.width - 4)
+	b.WriteString(borderStyle.Render(strings.TrimSuffix(list.String(), "\n")))
+
+	return b.String()
+}
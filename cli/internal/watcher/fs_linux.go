@@ -0,0 +1,28 @@
+package watcher
+
+import "syscall"
+
+// Filesystems where inotify only sees changes made on this machine, by
+// statfs magic number (see statfs(2))
+var remoteMagic = map[uint32]string{
+	0x6969:     "nfs",
+	0x517b:     "smb",
+	0xff534d42: "cifs",
+	0xfe534d42: "smb2",
+	0x65735546: "fuse", // sshfs, rclone, Docker Desktop's grpcfuse, ...
+	0x01021997: "9p",   // WSL2 Windows drives, VM shares
+	0x6a656a63: "virtiofs",
+	0x786f4256: "vboxsf",
+	0x00c36400: "ceph",
+}
+
+// remoteFS reports whether dir is on a network or FUSE filesystem, and
+// which
+func remoteFS(dir string) (string, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return "", false
+	}
+	name, ok := remoteMagic[uint32(st.Type)]
+	return name, ok
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package watcher
+
+// remoteFS only knows Linux filesystems; elsewhere polling has to be
+// chosen with ModePoll
+func remoteFS(dir string) (string, bool) {
+	return "", false
+}
@@ -0,0 +1,195 @@
+package watcher
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/daphen/notes-cli/internal/attachment"
+	"github.com/daphen/notes-cli/internal/hashing"
+)
+
+// fileState is what a poll remembers about a file until the next one
+type fileState struct {
+	modTime  time.Time
+	size     int64
+	checksum string // Notes only
+	pending  bool   // Attachment changed, not reported until it settles
+}
+
+// poll stands in for inotify: it scans the directory every interval and
+// reports notes and attachments that appeared or changed since the last
+// scan. Like the inotify loop, it reports removed attachments but not
+// removed notes, and attachments only once a scan finds them unchanged.
+func (w *Watcher) poll(ctx context.Context, changes chan<- FileChange) {
+	attrs := []any{"dir", w.dir, "interval", w.interval}
+	if w.reason != "" {
+		attrs = append(attrs, "reason", w.reason)
+	}
+	w.log.Info("polling for changes", attrs...)
+
+	// The first scan is the baseline: nothing has changed yet
+	known := make(map[string]fileState)
+	w.rescan(known)
+	for path, st := range known {
+		st.pending = false
+		known[path] = st
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.closed:
+			return
+		case <-ticker.C:
+		}
+
+		for _, change := range w.rescan(known) {
+			if !send(ctx, changes, change) {
+				return
+			}
+		}
+	}
+}
+
+// rescan walks the directory, updates known and returns what changed.
+// Size and mtime are checked first; a note whose content hashes the same
+// (e.g. touched, or saved without edits) isn't reported.
+func (w *Watcher) rescan(known map[string]fileState) []FileChange {
+	var changes []FileChange
+	seen := make(map[string]bool, len(known))
+	// Directories that couldn't be read, e.g. a stalled network share:
+	// what they held isn't known to be gone
+	var failed []string
+
+	filepath.WalkDir(w.dir, func(path string, d fs.DirEntry, err error) error {
+		// 🔵 GO CONCEPT: filepath.WalkDir
+		// Like Walk, but hands over a DirEntry instead of calling stat on
+		// every file - the size and mtime are only read for files we want.
+		relPath, _ := filepath.Rel(w.dir, path)
+		if err != nil {
+			w.log.Debug("scan failed", "path", relPath, "err", err)
+			if d == nil || d.IsDir() {
+				failed = append(failed, relPath)
+			} else {
+				seen[relPath] = true
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		isNote := strings.HasSuffix(path, ".md")
+		isAttachment := attachment.IsAttachment(relPath)
+		if (!isNote && !isAttachment) || w.ignore.Match(relPath) {
+			return nil
+		}
+
+		seen[relPath] = true
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		prev, ok := known[relPath]
+		unchanged := ok && prev.size == info.Size() && prev.modTime.Equal(info.ModTime())
+
+		// Attachments are hashed by the sync code. One that changed is
+		// reported by the next scan that finds it as it was, so files
+		// still being written aren't uploaded half-done.
+		if isAttachment {
+			if unchanged && !prev.pending {
+				return nil
+			}
+			known[relPath] = fileState{modTime: info.ModTime(), size: info.Size(), pending: !unchanged}
+			if unchanged {
+				changes = append(changes, FileChange{
+					Path:       relPath,
+					FullPath:   path,
+					Action:     "update",
+					Attachment: true,
+				})
+			}
+			return nil
+		}
+
+		if unchanged {
+			return nil
+		}
+		cur := fileState{modTime: info.ModTime(), size: info.Size()}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			w.log.Debug("skipping unreadable file", "path", relPath, "err", err)
+			return nil
+		}
+		cur.checksum = hashing.Sum(string(content))
+		known[relPath] = cur
+
+		if ok && prev.checksum == cur.checksum {
+			return nil
+		}
+		changes = append(changes, FileChange{
+			Path:     relPath,
+			FullPath: path,
+			Content:  string(content),
+			Action:   "update",
+		})
+		return nil
+	})
+
+	if slices.Contains(failed, ".") {
+		w.log.Warn("scan failed, not looking for removed files", "dir", w.dir)
+		return changes
+	}
+
+	var removed []FileChange
+	tracked := 0
+	for path := range known {
+		isAttachment := attachment.IsAttachment(path)
+		if isAttachment {
+			tracked++
+		}
+		if seen[path] || under(path, failed) {
+			continue
+		}
+		delete(known, path)
+		if isAttachment {
+			removed = append(removed, FileChange{
+				Path:       path,
+				FullPath:   filepath.Join(w.dir, path),
+				Action:     "delete",
+				Attachment: true,
+			})
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i].Path < removed[j].Path })
+
+	// As in reconcile: every attachment gone at once more likely means an
+	// unmounted directory than a clean-out
+	if len(removed) > 0 && len(removed) == tracked {
+		w.log.Warn("all attachments are missing, not deleting them on the server", "dir", w.dir, "attachments", len(removed))
+		removed = nil
+	}
+	return append(changes, removed...)
+}
+
+// under reports whether path lies in one of dirs
+func under(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
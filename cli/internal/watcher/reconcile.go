@@ -0,0 +1,86 @@
+package watcher
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/daphen/notes-cli/internal/hashing"
+)
+
+// SetBaseline makes Watch start with a scan for notes changed while
+// nobody was watching. synced is called then and returns the checksum of
+// every note as last synced, keyed by path. A path mapped to "" is left
+// alone, e.g. one waiting for a conflict to be resolved.
+func (w *Watcher) SetBaseline(synced func() map[string]string) {
+	w.baseline = synced
+}
+
+// reconcile compares the notes on disk with the baseline and returns a
+// synthetic change for each one created, edited or deleted since
+func (w *Watcher) reconcile() []FileChange {
+	if w.baseline == nil {
+		return nil
+	}
+	synced := w.baseline()
+	if len(synced) == 0 {
+		// Never synced: nothing to compare with, that's -push's job
+		return nil
+	}
+
+	notes, err := w.ReadAllNotes()
+	if err != nil {
+		w.log.Warn("startup scan failed", "dir", w.dir, "err", err)
+		return nil
+	}
+
+	var changes, deleted []FileChange
+	onDisk := make(map[string]bool, len(notes))
+	created := 0
+	for _, n := range notes {
+		onDisk[n.Path] = true
+
+		checksum, known := synced[n.Path]
+		switch {
+		case known && checksum == "":
+			continue
+		case !known:
+			n.Action = "create"
+			created++
+		case hashing.Matches(checksum, n.Content):
+			continue
+		}
+		changes = append(changes, n)
+	}
+
+	tracked := 0
+	for path, checksum := range synced {
+		// Ignored notes aren't read, but weren't deleted either
+		if checksum == "" || w.ignore.Match(path) {
+			continue
+		}
+		tracked++
+		if onDisk[path] {
+			continue
+		}
+		deleted = append(deleted, FileChange{
+			Path:     path,
+			FullPath: filepath.Join(w.dir, path),
+			Action:   "delete",
+		})
+	}
+	sort.Slice(deleted, func(i, j int) bool { return deleted[i].Path < deleted[j].Path })
+
+	// Every note gone at once more likely means an unmounted or replaced
+	// directory than a clean-out; deleting them all on the server would be
+	// hard to undo
+	if len(deleted) > 0 && len(deleted) == tracked {
+		w.log.Warn("all synced notes are missing, not deleting them on the server", "dir", w.dir, "notes", len(deleted))
+		deleted = nil
+	}
+
+	if len(changes) > 0 || len(deleted) > 0 {
+		w.log.Info("changed while not watching", "dir", w.dir,
+			"created", created, "updated", len(changes)-created, "deleted", len(deleted))
+	}
+	return append(changes, deleted...)
+}
@@ -1,56 +1,152 @@
 package watcher
 
 import (
-	"crypto/md5"
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+
+	"github.com/daphen/notes-cli/internal/attachment"
+	"github.com/daphen/notes-cli/internal/ignore"
 )
 
 // FileChange represents a change to a file
 type FileChange struct {
-	Path     string
-	FullPath string
-	Content  string
-	Action   string // "update" or "delete"
+	Path       string
+	FullPath   string
+	Content    string // Empty for attachments
+	Action     string // "create", "update" or "delete"
+	Attachment bool   // Binary attachment rather than a note
+}
+
+// Mode is how a Watcher notices changes
+type Mode string
+
+const (
+	ModeAuto   Mode = "auto"   // inotify, or polling where inotify can't work
+	ModeNotify Mode = "notify" // inotify (fsnotify) only
+	ModePoll   Mode = "poll"   // Periodic scans
+)
+
+// DefaultInterval is how often ModePoll scans the directory
+const DefaultInterval = 2 * time.Second
+
+// Options configures a Watcher
+type Options struct {
+	Mode     Mode          // Empty = ModeAuto
+	Interval time.Duration // Between polls, <= 0 = DefaultInterval
 }
 
 // Watcher watches a directory for file changes
 type Watcher struct {
-	dir        string
-	fsWatcher  *fsnotify.Watcher
-	debounce   map[string]time.Time
+	dir       string
+	fsWatcher *fsnotify.Watcher // nil when polling
+	debounce  map[string]time.Time
 	// 🔵 GO CONCEPT: Maps
 	// map[keyType]valueType - maps must be initialized with make() before use.
 	// This map tracks when files were last changed for debouncing.
-}
 
-// New creates a new file watcher
-func New(dir string) (*Watcher, error) {
-	fsWatcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create watcher: %w", err)
-	}
+	settling map[string]fileState // Attachments waiting to stop changing
+
+	// Polling
+	interval  time.Duration
+	reason    string // Why ModeAuto chose polling
+	closed    chan struct{}
+	closeOnce sync.Once
 
+	ignore   *ignore.Matcher // Paths never reported (nil = none)
+	baseline func() map[string]string
+	log      *slog.Logger
+}
+
+// New creates a new file watcher. ModeAuto polls when dir is on a network
+// or FUSE filesystem, where inotify sees no changes made elsewhere, or
+// when inotify can't be set up (e.g. the watch limit is reached).
+func New(dir string, opts Options) (*Watcher, error) {
 	w := &Watcher{
-		dir:       dir,
-		fsWatcher: fsWatcher,
-		debounce:  make(map[string]time.Time),
+		dir:      dir,
+		debounce: make(map[string]time.Time),
+		settling: make(map[string]fileState),
+		interval: opts.Interval,
+		closed:   make(chan struct{}),
+		log:      slog.New(slog.DiscardHandler),
 		// 🔵 GO CONCEPT: make()
 		// make() initializes maps, slices, and channels.
 		// Without this, debounce would be nil and cause a panic on access.
 	}
+	if w.interval <= 0 {
+		w.interval = DefaultInterval
+	}
+
+	switch opts.Mode {
+	case ModePoll:
+		return w, nil
+
+	case ModeNotify:
+		if err := w.startNotify(); err != nil {
+			return nil, err
+		}
+		return w, nil
+
+	case ModeAuto, "":
+		if fs, ok := remoteFS(dir); ok {
+			w.reason = fs + " filesystem"
+			return w, nil
+		}
+		if err := w.startNotify(); err != nil {
+			w.reason = err.Error()
+		}
+		return w, nil
+	}
+
+	return nil, fmt.Errorf("unknown watch mode %q (auto, notify, poll)", opts.Mode)
+}
+
+// startNotify sets up inotify watches for dir and its subdirectories
+func (w *Watcher) startNotify() error {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	w.fsWatcher = fsWatcher
 
 	// Add the directory to watch (recursively)
-	if err := w.addDirRecursive(dir); err != nil {
-		return nil, err
+	if err := w.addDirRecursive(w.dir); err != nil {
+		fsWatcher.Close()
+		w.fsWatcher = nil
+		return err
+	}
+	return nil
+}
+
+// Mode reports whether the watcher uses inotify or polls
+func (w *Watcher) Mode() Mode {
+	if w.fsWatcher == nil {
+		return ModePoll
 	}
+	return ModeNotify
+}
+
+// Interval is the time between polls
+func (w *Watcher) Interval() time.Duration {
+	return w.interval
+}
+
+// SetIgnore skips paths matching m in Watch and ReadAllNotes
+func (w *Watcher) SetIgnore(m *ignore.Matcher) {
+	w.ignore = m
+}
 
-	return w, nil
+// SetLogger reports watch errors and unreadable files to l
+func (w *Watcher) SetLogger(l *slog.Logger) {
+	w.log = l
 }
 
 // addDirRecursive adds a directory and all subdirectories to the watcher
@@ -72,17 +168,40 @@ func (w *Watcher) addDirRecursive(dir string) error {
 	})
 }
 
-// Watch starts watching for file changes and sends them on the returned channel
-func (w *Watcher) Watch() <-chan FileChange {
+// attachmentSettle is how long an attachment's size and mtime have to
+// stay the same before it's reported, so a file still being written or
+// copied in isn't uploaded half-done
+const attachmentSettle = time.Second
+
+// queueSize is how many changes Watch buffers while the caller is busy
+// pushing, so bursts of saves don't stall the event loop
+const queueSize = 64
+
+// Watch starts watching for file changes and sends them on the returned
+// channel. With a baseline set, changes made while nothing was watching
+// come first (see SetBaseline). The channel is closed once ctx is done or
+// the watcher is closed; changes already queued can still be received
+// after that.
+func (w *Watcher) Watch(ctx context.Context) <-chan FileChange {
 	// 🔵 GO CONCEPT: Channels
 	// Channels are Go's way of communicating between goroutines (threads).
 	// <-chan means "receive-only channel" - callers can only read from it.
 	// This is type-safe: you can't accidentally write to a read-only channel.
 
-	changes := make(chan FileChange)
-	// 🔵 GO CONCEPT: Unbuffered channels
-	// make(chan T) creates an unbuffered channel - sends block until received.
-	// You can also do make(chan T, n) for a buffered channel with n slots.
+	changes := make(chan FileChange, queueSize)
+	// 🔵 GO CONCEPT: Buffered channels
+	// make(chan T, n) holds up to n values before a send blocks, so the
+	// watcher keeps reading events while the receiver is busy.
+
+	if w.fsWatcher == nil {
+		go func() {
+			defer close(changes)
+			if w.catchUp(ctx, changes) {
+				w.poll(ctx, changes)
+			}
+		}()
+		return changes
+	}
 
 	go func() {
 		// 🔵 GO CONCEPT: Goroutines
@@ -93,6 +212,14 @@ func (w *Watcher) Watch() <-chan FileChange {
 		defer close(changes)
 		// Close the channel when this goroutine exits
 
+		// Events arriving meanwhile wait in fsnotify's queue
+		if !w.catchUp(ctx, changes) {
+			return
+		}
+
+		settle := time.NewTicker(attachmentSettle)
+		defer settle.Stop()
+
 		for {
 			// 🔵 GO CONCEPT: Infinite loops
 			// for { } is an infinite loop (like while(true))
@@ -104,6 +231,9 @@ func (w *Watcher) Watch() <-chan FileChange {
 			// It waits for whichever channel operation can proceed.
 			// This is how Go does non-blocking I/O.
 
+			case <-ctx.Done():
+				return
+
 			case event, ok := <-w.fsWatcher.Events:
 				// 🔵 GO CONCEPT: Channel receive with ok check
 				// val, ok := <-ch checks if the channel is closed.
@@ -113,8 +243,37 @@ func (w *Watcher) Watch() <-chan FileChange {
 					return // Channel closed, exit goroutine
 				}
 
-				// Only process .md files
-				if !strings.HasSuffix(event.Name, ".md") {
+				// Only process .md files and attachments
+				relPath, _ := filepath.Rel(w.dir, event.Name)
+				isAttachment := attachment.IsAttachment(relPath)
+				if !strings.HasSuffix(event.Name, ".md") && !isAttachment {
+					continue
+				}
+				if w.ignore.Match(relPath) {
+					continue
+				}
+
+				// Attachments are hashed and uploaded by the sync code, so
+				// only the path is needed. Create covers files moved in;
+				// they're reported once they stop changing.
+				if isAttachment {
+					switch {
+					case event.Op&(fsnotify.Write|fsnotify.Create) != 0:
+						if info, err := os.Stat(event.Name); err == nil && !info.IsDir() {
+							w.settling[relPath] = fileState{modTime: info.ModTime(), size: info.Size()}
+						}
+					case event.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+						delete(w.settling, relPath)
+						change := FileChange{
+							Path:       relPath,
+							FullPath:   event.Name,
+							Action:     "delete",
+							Attachment: true,
+						}
+						if !send(ctx, changes, change) {
+							return
+						}
+					}
 					continue
 				}
 
@@ -135,27 +294,35 @@ func (w *Watcher) Watch() <-chan FileChange {
 
 					content, err := os.ReadFile(event.Name)
 					if err != nil {
-						continue // Skip if we can't read
+						// Skip if we can't read, e.g. removed right away
+						w.log.Debug("skipping unreadable file", "path", relPath, "err", err)
+						continue
 					}
 
-					relPath, _ := filepath.Rel(w.dir, event.Name)
 					change = FileChange{
 						Path:     relPath,
 						FullPath: event.Name,
 						Content:  string(content),
 						Action:   "update",
 					}
-					changes <- change
-					// 🔵 GO CONCEPT: Channel send
-					// <- sends a value into a channel.
-					// This will block until someone receives it (unless buffered).
+					if !send(ctx, changes, change) {
+						return
+					}
+				}
+
+			case <-settle.C:
+				for _, change := range w.settled() {
+					if !send(ctx, changes, change) {
+						return
+					}
 				}
 
 			case err, ok := <-w.fsWatcher.Errors:
 				if !ok {
 					return
 				}
-				fmt.Printf("Watcher error: %v\n", err)
+				// Printing would draw over the TUI
+				w.log.Warn("watcher error", "dir", w.dir, "err", err)
 			}
 		}
 	}()
@@ -166,13 +333,101 @@ func (w *Watcher) Watch() <-chan FileChange {
 	// The channel connects them - the goroutine writes, the caller reads.
 }
 
+// settled returns the attachments whose size and mtime haven't changed
+// since the last check and stops tracking them. Files that are gone are
+// dropped; their Remove event reports them.
+func (w *Watcher) settled() []FileChange {
+	var changes []FileChange
+	for relPath, prev := range w.settling {
+		fullPath := filepath.Join(w.dir, relPath)
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			delete(w.settling, relPath)
+			continue
+		}
+		if info.Size() != prev.size || !info.ModTime().Equal(prev.modTime) {
+			w.settling[relPath] = fileState{modTime: info.ModTime(), size: info.Size()}
+			continue
+		}
+
+		delete(w.settling, relPath)
+		changes = append(changes, FileChange{
+			Path:       relPath,
+			FullPath:   fullPath,
+			Action:     "update",
+			Attachment: true,
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// Queued returns the changes already waiting on a channel from Watch,
+// without blocking, so they can be pushed together
+func Queued(changes <-chan FileChange) []FileChange {
+	var queued []FileChange
+	for {
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				return queued
+			}
+			queued = append(queued, change)
+		default:
+			return queued
+		}
+	}
+}
+
+// catchUp sends the changes found by reconcile, reporting whether Watch
+// should carry on
+func (w *Watcher) catchUp(ctx context.Context, changes chan<- FileChange) bool {
+	for _, change := range w.reconcile() {
+		if !send(ctx, changes, change) {
+			return false
+		}
+	}
+	return true
+}
+
+// send queues a change, giving up once ctx is done
+func send(ctx context.Context, changes chan<- FileChange, change FileChange) bool {
+	select {
+	case changes <- change:
+		// 🔵 GO CONCEPT: Channel send
+		// <- sends a value into a channel. It blocks while the buffer is
+		// full, which is why it sits in a select with ctx.Done().
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
 // Close stops the watcher
 func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() { close(w.closed) })
+	if w.fsWatcher == nil {
+		return nil
+	}
 	return w.fsWatcher.Close()
 }
 
+// ReadNotes reads all .md files in dir not matched by m, without setting
+// up a watcher
+func ReadNotes(ctx context.Context, dir string, m *ignore.Matcher) ([]FileChange, error) {
+	w := &Watcher{dir: dir, ignore: m}
+	return w.ReadAllNotesProgress(ctx, nil)
+}
+
 // ReadAllNotes reads all .md files in the directory
 func (w *Watcher) ReadAllNotes() ([]FileChange, error) {
+	return w.ReadAllNotesProgress(context.Background(), nil)
+}
+
+// ReadAllNotesProgress is ReadAllNotes for large directories: progress,
+// if set, is called after each note with the count and bytes read so far,
+// and reading stops with ctx's error once it's done
+func (w *Watcher) ReadAllNotesProgress(ctx context.Context, progress func(notes int, bytes int64)) ([]FileChange, error) {
 	// 🔵 GO CONCEPT: Slices
 	// []T is a slice - a dynamically-sized array.
 	// Unlike arrays, slices can grow with append().
@@ -180,18 +435,27 @@ func (w *Watcher) ReadAllNotes() ([]FileChange, error) {
 	var notes []FileChange
 	// Zero value of a slice is nil, which is fine - we can append to it.
 
+	var total int64
+
 	err := filepath.Walk(w.dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
 		if !info.IsDir() && strings.HasSuffix(path, ".md") {
+			relPath, _ := filepath.Rel(w.dir, path)
+			if w.ignore.Match(relPath) {
+				return nil
+			}
+
 			content, err := os.ReadFile(path)
 			if err != nil {
 				return err
 			}
 
-			relPath, _ := filepath.Rel(w.dir, path)
 			notes = append(notes, FileChange{
 				// 🔵 GO CONCEPT: append()
 				// append() adds elements to a slice.
@@ -203,6 +467,11 @@ func (w *Watcher) ReadAllNotes() ([]FileChange, error) {
 				Content:  string(content),
 				Action:   "update",
 			})
+
+			total += int64(len(content))
+			if progress != nil {
+				progress(len(notes), total)
+			}
 		}
 
 		return nil
@@ -210,18 +479,3 @@ func (w *Watcher) ReadAllNotes() ([]FileChange, error) {
 
 	return notes, err
 }
-
-// CalculateChecksum computes MD5 hash of a file
-func CalculateChecksum(content string) string {
-	// 🔵 GO CONCEPT: Type conversion
-	// []byte(content) converts string to byte slice.
-	// In Go, strings are immutable, []byte is mutable.
-
-	hash := md5.Sum([]byte(content))
-	// 🔵 GO CONCEPT: Arrays vs Slices
-	// md5.Sum returns [16]byte (fixed-size array).
-	// We convert it to a slice with [:] to use fmt.Sprintf.
-
-	return fmt.Sprintf("%x", hash[:])
-	// %x formats bytes as lowercase hex
-}